@@ -8,9 +8,9 @@ import (
 	"time"
 
 	"github.com/apibillme/cache"
-	"github.com/lestrrat-go/jwx/jws"
-
+	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
 
 	"github.com/spf13/cast"
 	"github.com/valyala/fasthttp"
@@ -21,110 +21,261 @@ import (
 )
 
 // reference vars here for stubbing
-var jwkFetch = jwk.Fetch
+var jwkFetch = fetchJWKS
 var jwsVerifyWithJWK = jws.VerifyWithJWK
 var jwtParseString = jwt.ParseString
+var jwsParseString = jws.ParseString
 
-func validateToken(jwkURL string, jwtToken string) (*jwt.Token, error) {
-	// get JWKs and validate them against JWT token
-	set, err := jwkFetch(jwkURL)
+func (v *Validator) validateToken(jwtToken string) (*jwt.Token, error) {
+	header, err := parseHeader(jwtToken)
 	if err != nil {
-		return nil, err
+		return nil, causedError(ErrMalformedToken, err)
+	}
+	// reject unexpected algorithms before any crypto is attempted
+	jwkURL, algorithms := v.keys()
+	alg := header.Algorithm()
+	if !allowedAlgorithm(algorithms, alg.String()) {
+		return nil, claimError(ErrInvalidAlgorithm, "alg", alg.String())
 	}
 
-	var errstrings []string
+	// get JWKs (cached) and validate them against JWT token
+	set, err := v.jwks.get(jwkURL)
+	if err != nil {
+		return nil, causedError(ErrJWKSUnavailable, err)
+	}
 
-	matches := 0
-	for _, key := range set.Keys {
-		_, err = jwsVerifyWithJWK([]byte(jwtToken), key)
+	// only try the key the token was signed with
+	key := lookupKey(set, header)
+	if key == nil {
+		// the signing keys may have been rotated - refetch (rate limited) and look again
+		set, err = v.jwks.refresh(jwkURL)
 		if err == nil {
-			matches++
-		} else {
-			errstrings = append(errstrings, err.Error())
+			key = lookupKey(set, header)
+		}
+		if key == nil {
+			return nil, claimError(ErrUnknownKID, "kid", header.KeyID())
 		}
 	}
+	// the key is verified with its own alg so it must agree with the token
+	if key.Algorithm() != alg.String() {
+		return nil, claimError(ErrInvalidAlgorithm, "key alg", key.Algorithm())
+	}
+	_, err = jwsVerifyWithJWK([]byte(jwtToken), key)
+	if err != nil {
+		return nil, causedError(ErrInvalidSignature, err)
+	}
 
 	// if JWT validated then verify token
-	if matches > 0 {
-		return verifyToken(jwtToken)
+	return v.verifyToken(jwtToken)
+}
+
+func allowedAlgorithm(algorithms []jwa.SignatureAlgorithm, alg string) bool {
+	if alg == "" || alg == jwa.NoSignature.String() {
+		return false
+	}
+	for _, allowed := range algorithms {
+		if alg == allowed.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// parseHeader - parse the JOSE header of the token without verifying it
+func parseHeader(jwtToken string) (jws.Headers, error) {
+	msg, err := jwsParseString(jwtToken)
+	if err != nil {
+		return nil, err
+	}
+	signatures := msg.Signatures()
+	if len(signatures) == 0 || signatures[0].ProtectedHeaders() == nil {
+		return nil, errors.New("token has no JOSE header")
 	}
+	return signatures[0].ProtectedHeaders(), nil
+}
 
-	// token is invalid
-	return nil, errors.New(strings.Join(errstrings, "\n"))
+// lookupKey - find the JWK named by the kid header (or x5t when there is no kid match)
+func lookupKey(set *jwk.Set, header jws.Headers) jwk.Key {
+	if kid := header.KeyID(); kid != "" {
+		if keys := set.LookupKeyID(kid); len(keys) > 0 {
+			return keys[0]
+		}
+	}
+	if x5t := header.X509CertThumbprint(); x5t != "" {
+		for _, key := range set.Keys {
+			if key.X509CertThumbprint() == x5t {
+				return key
+			}
+		}
+	}
+	return nil
 }
 
-func verifyToken(jwtToken string) (*jwt.Token, error) {
+func (v *Validator) verifyToken(jwtToken string) (*jwt.Token, error) {
 	// parse & verify claims of JWT token
 	token, err := jwtParseString(jwtToken)
 	if err != nil {
-		return nil, err
+		return nil, causedError(ErrMalformedToken, err)
 	}
-	err = token.Verify()
+	err = v.verifyClaims(token)
 	if err != nil {
 		return nil, err
 	}
 	return token, nil
 }
 
+// verifyClaims - verify the time based claims & the claims policy of an already parsed token - both fresh & cached tokens.
+// NumericDates have a precision of seconds, so the clock is truncated to the second.
+func (v *Validator) verifyClaims(token *jwt.Token) error {
+	now := v.now().Truncate(time.Second)
+	// expired at exp (RFC 7519 section 4.1.4)
+	if exp := token.Expiration(); !exp.IsZero() && !now.Before(exp.Add(v.leeway)) {
+		return claimError(ErrExpired, "exp", exp.Format(time.RFC3339))
+	}
+	// issued in the future
+	if iat := token.IssuedAt(); !iat.IsZero() && now.Before(iat.Add(-v.leeway)) {
+		return claimError(ErrNotYetValid, "iat", iat.Format(time.RFC3339))
+	}
+	// valid from nbf on (RFC 7519 section 4.1.5)
+	if nbf := token.NotBefore(); !nbf.IsZero() && now.Before(nbf.Add(-v.leeway)) {
+		return claimError(ErrNotYetValid, "nbf", nbf.Format(time.RFC3339))
+	}
+	return v.verifyPolicy(token)
+}
+
+// bearerScheme - the authentication scheme of RFC 6750
+const bearerScheme = "Bearer"
+
 func extractBearerTokenNet(req *http.Request) []string {
-	bearerToken := req.Header.Get("Authorization")
-	return strings.Split(bearerToken, " ")
+	return req.Header["Authorization"]
 }
 
 func extractBearerTokenFast(req *fasthttp.RequestCtx) []string {
-	bearerTokenBytes := req.Request.Header.Peek("Authorization")
-	bearerToken := cast.ToString(bearerTokenBytes)
-	return strings.Split(bearerToken, " ")
+	var headers []string
+	req.Request.Header.VisitAll(func(key []byte, value []byte) {
+		if strings.EqualFold(cast.ToString(key), "Authorization") {
+			headers = append(headers, cast.ToString(value))
+		}
+	})
+	return headers
 }
 
-func verifyBearerToken(tokenParts []string) (string, error) {
-	if len(tokenParts) < 2 {
-		return "", errors.New("Authorization header must have a Bearer token")
+// verifyBearerToken - the token of the Authorization header (RFC 6750 section 2.1) - the scheme is
+// case-insensitive (RFC 7235 section 2.1) and any whitespace may surround it and the token
+func verifyBearerToken(headers []string) (string, error) {
+	if len(headers) > 1 {
+		// more than one credential
+		return "", ErrMalformedHeader
+	}
+	var fields []string
+	if len(headers) == 1 {
+		fields = strings.Fields(headers[0])
 	}
-	if tokenParts[0] != "Bearer" {
-		return "", errors.New("Authorization header must have a Bearer token")
+	switch {
+	case len(fields) == 0:
+		return "", ErrMissingToken
+	case !strings.EqualFold(fields[0], bearerScheme):
+		return "", claimError(ErrInvalidScheme, "scheme", fields[0])
+	case len(fields) != 2:
+		// no token, or more than one credential
+		return "", ErrMalformedHeader
 	}
-	return tokenParts[1], nil
+	return fields[1], nil
 }
 
 func getJwtTokenFast(req *fasthttp.RequestCtx) (string, error) {
-	tokenParts := extractBearerTokenFast(req)
-	return verifyBearerToken(tokenParts)
+	headers := extractBearerTokenFast(req)
+	return verifyBearerToken(headers)
 }
 
 func getJwtTokenNet(req *http.Request) (string, error) {
-	tokenParts := extractBearerTokenNet(req)
-	return verifyBearerToken(tokenParts)
+	headers := extractBearerTokenNet(req)
+	return verifyBearerToken(headers)
 }
 
-func processToken(cache cache.Cache, jwtToken string, jwkURL string, audience string, issuer string) (*jwt.Token, error) {
-	// check if token is in cache
-	_, ok := cache.Get(jwtToken)
-
-	// if not then validate & verify token and save in db
-	if !ok {
-		token, err := validateToken(jwkURL, jwtToken)
-		if err != nil {
+func (v *Validator) processToken(jwtToken string) (*jwt.Token, error) {
+	// check if token is in cache - the key is the whole signed (or introspected) token so a hit has passed the signature check
+	cached, _ := v.cache.Get(jwtToken)
+	// anything but a token (e.g. put in a shared cache by someone else) is a miss
+	if token, ok := cached.(*jwt.Token); ok {
+		// never serve a cached token past its validity
+		if err := v.verifyClaims(token); err != nil {
+			v.cache.Del(jwtToken)
 			return nil, err
 		}
-		// validate audience
-		if token.Audience() != audience {
-			return nil, errors.New("audience is not valid")
-		}
-		// validate issuer
-		if token.Issuer() != issuer {
-			return nil, errors.New("issuer is not valid")
+		// the cache may be shared with validators for other audiences & issuers
+		if err := v.verifyRecipient(token); err != nil {
+			return nil, err
 		}
+		return token, nil
+	}
 
-		// set in cache
-		cache.Set(jwtToken, jwtToken)
+	// if not then validate & verify token and save in db
+	var token *jwt.Token
+	var err error
+	if v.introspection != nil {
+		token, err = v.introspect(jwtToken)
+	} else {
+		token, err = v.validateToken(jwtToken)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := v.verifyRecipient(token); err != nil {
+		return nil, err
 	}
 
-	// if so then only verify token
-	return verifyToken(jwtToken)
+	// set verified claims in cache until the token expires
+	if ttl := v.entryTTL(token); ttl > 0 {
+		v.cache.SetWithTTL(jwtToken, token, ttl)
+	}
+	return token, nil
 }
 
-// GetEmail - get email as a custom claim from the access_token
+// verifyRecipient - the token was issued by the validator's issuer for one of its audiences
+func (v *Validator) verifyRecipient(token *jwt.Token) error {
+	// validate audience
+	if !v.validAudience(token) {
+		audiences, _ := GetAudiences(token)
+		return claimError(ErrInvalidAudience, "aud", strings.Join(audiences, " "))
+	}
+	// validate issuer
+	if token.Issuer() != v.issuer {
+		return claimError(ErrInvalidIssuer, "iss", token.Issuer())
+	}
+	return nil
+}
+
+// entryTTL - min(configured TTL, exp - now) - a configured TTL of 0 means bounded by exp only
+func (v *Validator) entryTTL(token *jwt.Token) time.Duration {
+	ttl := v.cacheTTL
+	if exp := token.Expiration(); !exp.IsZero() {
+		// the token is accepted until exp + leeway
+		untilExp := exp.Add(v.leeway).Sub(v.now())
+		if ttl <= 0 || untilExp < ttl {
+			ttl = untilExp
+		}
+	}
+	return ttl
+}
+
+// validAudience - any of the token's audiences is one of the accepted audiences
+func (v *Validator) validAudience(token *jwt.Token) bool {
+	audiences, err := GetAudiences(token)
+	if err != nil {
+		return false
+	}
+	for _, audience := range audiences {
+		for _, allowed := range v.audiences {
+			if audience == allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GetEmail - get email as a custom claim from the access_token - the audience is the namespace of the claim
 func GetEmail(token *jwt.Token, audience string) (string, error) {
 	// have to escape the periods in the URL (gjson specific)
 	field := audience + "email"
@@ -132,6 +283,27 @@ func GetEmail(token *jwt.Token, audience string) (string, error) {
 	return tokenParser(token, field)
 }
 
+// GetAudiences - get the audiences of the token - the aud claim may be a string or an array
+func GetAudiences(token *jwt.Token) ([]string, error) {
+	jsonBytes, err := token.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	result := gjson.GetBytes(jsonBytes, "aud")
+	var audiences []string
+	if result.IsArray() {
+		for _, audience := range result.Array() {
+			audiences = append(audiences, audience.String())
+		}
+	} else if result.String() != "" {
+		audiences = append(audiences, result.String())
+	}
+	if len(audiences) == 0 {
+		return nil, errors.New("there are no aud")
+	}
+	return audiences, nil
+}
+
 // URLScope - url scope type
 type URLScope struct {
 	Method string
@@ -140,11 +312,16 @@ type URLScope struct {
 
 // GetURLScopes - get the URL scopes from the scopes from the token
 func GetURLScopes(token *jwt.Token) ([]URLScope, error) {
-	var urlScopes []URLScope
 	scopes, err := tokenParser(token, "scope")
 	if err != nil {
 		return nil, err
 	}
+	return parseURLScopes(scopes), nil
+}
+
+// parseURLScopes - the method:url scopes of a space-separated scope string
+func parseURLScopes(scopes string) []URLScope {
+	var urlScopes []URLScope
 	r := regexp.MustCompile(`(?m)(\S+:\S+)`)
 	urlScopesArray := r.FindAllString(scopes, -1)
 	for _, urlScope := range urlScopesArray {
@@ -155,7 +332,7 @@ func GetURLScopes(token *jwt.Token) ([]URLScope, error) {
 		}
 		urlScopes = append(urlScopes, urlScopeObj)
 	}
-	return urlScopes, nil
+	return urlScopes
 }
 
 func tokenParser(token *jwt.Token, field string) (string, error) {
@@ -181,33 +358,63 @@ func GetScopes(token *jwt.Token) ([]string, error) {
 	return scopes, nil
 }
 
-// Cached - the cache for the tokens
+// GetPermissions - get the Auth0 RBAC permissions of the token
+func GetPermissions(token *jwt.Token) ([]string, error) {
+	jsonBytes, err := token.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	var permissions []string
+	for _, permission := range gjson.GetBytes(jsonBytes, "permissions").Array() {
+		if permission.String() != "" {
+			permissions = append(permissions, permission.String())
+		}
+	}
+	if len(permissions) == 0 {
+		return nil, errors.New("there are no permissions")
+	}
+	return permissions, nil
+}
+
+// Cached - the cache for the tokens used by Validate & ValidateFast
+//
+// Deprecated: give each Validator its own cache with WithCache.
 var Cached cache.Cache
 
-// New - set cache options - total keys at one-time and ttl in seconds
+// cachedTTL - the ttl given to New
+var cachedTTL time.Duration
+
+// New - set cache options for Validate & ValidateFast - total keys at one-time and ttl in seconds
+//
+// Deprecated: use NewValidator with WithCache.
 func New(keyCapacity int, ttl int64) {
-	globalTTL := time.Duration(ttl)
-	Cached = cache.New(keyCapacity, cache.WithTTL(globalTTL*time.Second))
+	cachedTTL = time.Duration(ttl) * time.Second
+	Cached = cache.New(keyCapacity, cache.WithTTL(cachedTTL))
+}
+
+// jwksCached - the cache for the JWKs shared by Validate & ValidateFast
+var jwksCached = newJWKSCache(defaultClient, time.Now)
+
+// defaultValidator - the validator behind Validate & ValidateFast
+func defaultValidator(jwkURL string, audience string, issuer string) *Validator {
+	return &Validator{
+		jwkURL:     jwkURL,
+		audiences:  []string{audience},
+		issuer:     issuer,
+		algorithms: DefaultAlgorithms,
+		cache:      asTTLCache(Cached, time.Now),
+		cacheTTL:   cachedTTL,
+		jwks:       jwksCached,
+		now:        time.Now,
+	}
 }
 
 // ValidateFast - validate with JWK & JWT Auth0 & audience & issuer for fasthttp
 func ValidateFast(jwkURL string, audience string, issuer string, req *fasthttp.RequestCtx) (*jwt.Token, error) {
-	// extract token from header
-	jwtToken, err := getJwtTokenFast(req)
-	if err != nil {
-		return nil, err
-	}
-	// process token
-	return processToken(Cached, jwtToken, jwkURL, audience, issuer)
+	return defaultValidator(jwkURL, audience, issuer).ValidateFast(req)
 }
 
 // Validate - validate with JWK & JWT Auth0 & audience & issuer for net/http
 func Validate(jwkURL string, audience string, issuer string, req *http.Request) (*jwt.Token, error) {
-	// extract token from header
-	jwtToken, err := getJwtTokenNet(req)
-	if err != nil {
-		return nil, err
-	}
-	// process token
-	return processToken(Cached, jwtToken, jwkURL, audience, issuer)
+	return defaultValidator(jwkURL, audience, issuer).Validate(req)
 }
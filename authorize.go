@@ -0,0 +1,111 @@
+package auth0
+
+import (
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/valyala/fasthttp"
+)
+
+// selfParam - the path parameter bound to the token subject, e.g. GET:/users/{self}
+const selfParam = "{self}"
+
+// Authorize - nil if one of the token's URL scopes allows the method & path, otherwise ErrInsufficientScope.
+// URL scopes look like GET:/users/{id} - * matches any method, a * segment matches any one segment
+// (or the rest of the path when last), {param} matches any one segment and {self} only the token subject.
+func Authorize(token *jwt.Token, method string, path string) error {
+	return ScopeClaim.Authorize(token, method, path)
+}
+
+// Authorize - Authorize reading the URL scopes from the source
+func (s ScopeSource) Authorize(token *jwt.Token, method string, path string) error {
+	for _, urlScope := range s.URLScopes(token) {
+		if urlScope.matches(method, path, token.Subject()) {
+			return nil
+		}
+	}
+	// the request goes in the error for the caller, never in the scope of the challenge
+	return claimError(ErrInsufficientScope, "request", strings.ToUpper(method)+":"+path)
+}
+
+// RequireURLScopes - net/http middleware that lets through only requests allowed by Authorize - use behind Handler
+func RequireURLScopes(next http.Handler) http.Handler {
+	return ScopeClaim.RequireURLScopes(next)
+}
+
+// RequireURLScopesFast - fasthttp middleware that lets through only requests allowed by Authorize - use behind HandlerFast
+func RequireURLScopesFast(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return ScopeClaim.RequireURLScopesFast(next)
+}
+
+// RequireURLScopes - RequireURLScopes reading the URL scopes from the source
+func (s ScopeSource) RequireURLScopes(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		token, ok := TokenFromContext(req.Context())
+		if !ok {
+			rejectRequest(w, req, ErrMissingToken)
+			return
+		}
+		if err := s.Authorize(token, req.Method, req.URL.Path); err != nil {
+			rejectRequest(w, req, err)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// RequireURLScopesFast - RequireURLScopesFast reading the URL scopes from the source
+func (s ScopeSource) RequireURLScopesFast(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		token, ok := TokenFromContextFast(ctx)
+		if !ok {
+			rejectRequestFast(ctx, ErrMissingToken)
+			return
+		}
+		if err := s.Authorize(token, string(ctx.Method()), string(ctx.Path())); err != nil {
+			rejectRequestFast(ctx, err)
+			return
+		}
+		next(ctx)
+	}
+}
+
+// matches - the URL scope allows the method & request path for the subject - the path is cleaned
+// first so dot segments can't climb out of a wildcard (GET:/users/* never allows /users/../admin)
+func (s URLScope) matches(method string, requestPath string, subject string) bool {
+	if s.Method != "*" && !strings.EqualFold(s.Method, method) {
+		return false
+	}
+
+	patterns := pathSegments(s.URL)
+	segments := pathSegments(path.Clean("/" + requestPath))
+	for i, pattern := range patterns {
+		if i >= len(segments) {
+			return false
+		}
+		switch {
+		case pattern == "*" && i == len(patterns)-1:
+			return true
+		case pattern == "*":
+		case pattern == selfParam:
+			if subject == "" || segments[i] != subject {
+				return false
+			}
+		case strings.HasPrefix(pattern, "{") && strings.HasSuffix(pattern, "}"):
+		case pattern != segments[i]:
+			return false
+		}
+	}
+	return len(patterns) == len(segments)
+}
+
+// pathSegments - the segments of a path, ignoring leading & trailing slashes
+func pathSegments(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
@@ -0,0 +1,142 @@
+package auth0
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/apibillme/stubby"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/valyala/fasthttp"
+)
+
+func TestAuthorize(t *testing.T) {
+
+	Convey("Authorize", t, func() {
+		issuer := "https://example.auth0.com/"
+		audience := "https://httpbin.org/"
+
+		stub := stubby.StubFunc(&jwkFetch, testSet(), time.Duration(-1), nil)
+		defer stub.Reset()
+
+		v, err := NewValidator(
+			WithJWKSURL("https://example.auth0.com/jwks.json"),
+			WithAudiences(audience),
+			WithIssuer(issuer),
+		)
+		So(err, ShouldBeNil)
+
+		token, err := v.ValidateToken(scopedToken(issuer, audience, "openid get:/users *:/orders/* PUT:/users/{self} GET:/users/{id}/orders"))
+		So(err, ShouldBeNil)
+
+		Convey("Success - method is case-insensitive", func() {
+			So(Authorize(token, "GET", "/users"), ShouldBeNil)
+			So(Authorize(token, "GET", "/users/"), ShouldBeNil)
+		})
+
+		Convey("Success - * method & trailing * segment", func() {
+			So(Authorize(token, "DELETE", "/orders/1"), ShouldBeNil)
+			So(Authorize(token, "POST", "/orders/1/items/2"), ShouldBeNil)
+		})
+
+		Convey("Success - path parameter", func() {
+			So(Authorize(token, "GET", "/users/42/orders"), ShouldBeNil)
+		})
+
+		Convey("Success - {self} is the token subject", func() {
+			So(Authorize(token, "PUT", "/users/user@email.com"), ShouldBeNil)
+		})
+
+		Convey("Failure - {self} is another subject", func() {
+			err := Authorize(token, "PUT", "/users/other@email.com")
+			So(errors.Is(err, ErrInsufficientScope), ShouldBeTrue)
+			var validationErr *ValidationError
+			So(errors.As(err, &validationErr), ShouldBeTrue)
+			So(validationErr.Value, ShouldEqual, "PUT:/users/other@email.com")
+		})
+
+		Convey("Failure - method, segment count or path differs", func() {
+			So(Authorize(token, "POST", "/users"), ShouldBeError)
+			So(Authorize(token, "GET", "/orders"), ShouldBeError)
+			So(Authorize(token, "GET", "/users/42"), ShouldBeError)
+			So(Authorize(token, "GET", "/users/42/orders/1"), ShouldBeError)
+			So(Authorize(token, "GET", "/accounts"), ShouldBeError)
+		})
+
+		Convey("Failure - dot segments can't climb out of a wildcard", func() {
+			So(Authorize(token, "DELETE", "/orders/../admin"), ShouldBeError)
+			So(Authorize(token, "DELETE", "/orders/1/../../admin/x"), ShouldBeError)
+			So(Authorize(token, "DELETE", "/orders/./1"), ShouldBeNil)
+			So(Authorize(token, "GET", "/users/42/../42/orders"), ShouldBeNil)
+			So(Authorize(token, "GET", "/users/42/orders/.."), ShouldBeError)
+		})
+
+		Convey("RequireURLScopes", func() {
+			called := false
+			handler := v.Handler(RequireURLScopes(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				called = true
+			})))
+			jwtToken := scopedToken(issuer, audience, "GET:/users")
+
+			req := httptest.NewRequest("POST", "http://example.com/users", nil)
+			req.Header.Set("Authorization", "Bearer "+jwtToken)
+			res := httptest.NewRecorder()
+			handler.ServeHTTP(res, req)
+			So(called, ShouldBeFalse)
+			So(res.Code, ShouldEqual, http.StatusForbidden)
+			So(res.Header().Get("WWW-Authenticate"), ShouldEqual, `Bearer error="insufficient_scope", error_description="insufficient scope"`)
+
+			req = httptest.NewRequest("GET", "http://example.com/users?page=2", nil)
+			req.Header.Set("Authorization", "Bearer "+jwtToken)
+			res = httptest.NewRecorder()
+			handler.ServeHTTP(res, req)
+			So(called, ShouldBeTrue)
+			So(res.Code, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("RequireURLScopesFast", func() {
+			called := false
+			handler := v.HandlerFast(RequireURLScopesFast(func(ctx *fasthttp.RequestCtx) {
+				called = true
+			}))
+
+			ctx := &fasthttp.RequestCtx{}
+			ctx.Request.Header.SetMethod("DELETE")
+			ctx.Request.SetRequestURI("/users/1")
+			ctx.Request.Header.Set("Authorization", "Bearer "+scopedToken(issuer, audience, "*:/users/{id}"))
+			handler(ctx)
+			So(called, ShouldBeTrue)
+
+			called = false
+			ctx.Request.SetRequestURI("/accounts/1")
+			handler(ctx)
+			So(called, ShouldBeFalse)
+			So(ctx.Response.StatusCode(), ShouldEqual, fasthttp.StatusForbidden)
+		})
+
+		Convey("RequireURLScopesFast - the request path is never echoed into the response headers", func() {
+			called := false
+			handler := v.HandlerFast(RequireURLScopesFast(func(ctx *fasthttp.RequestCtx) {
+				called = true
+			}))
+
+			ctx := &fasthttp.RequestCtx{}
+			ctx.Request.SetRequestURI("/a%22%0d%0aSet-Cookie:%20evil=1")
+			ctx.Request.Header.Set("Authorization", "Bearer "+scopedToken(issuer, audience, "GET:/users"))
+			handler(ctx)
+			So(called, ShouldBeFalse)
+			So(ctx.Response.StatusCode(), ShouldEqual, fasthttp.StatusForbidden)
+			So(ctx.Response.Header.Peek("Set-Cookie"), ShouldBeEmpty)
+			authenticate := string(ctx.Response.Header.Peek("WWW-Authenticate"))
+			So(authenticate, ShouldEqual, `Bearer error="insufficient_scope", error_description="insufficient scope"`)
+			So(ctx.Response.Header.String(), ShouldNotContainSubstring, "evil")
+		})
+
+		Convey("ErrorResponse - scope values are quoted-string safe", func() {
+			_, authenticate := ErrorResponse(claimError(ErrInsufficientScope, "scope", "read\"\r\nSet-Cookie: evil=1"))
+			So(authenticate, ShouldEqual, `Bearer error="insufficient_scope", error_description="insufficient scope", scope="read'Set-Cookie: evil=1"`)
+		})
+	})
+}
@@ -0,0 +1,81 @@
+package auth0
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwt"
+)
+
+// DecodeClaim - decode a claim into v as encoding/json would - the name is used as is,
+// so namespaced claims like https://example.com/roles need no escaping
+func DecodeClaim(token *jwt.Token, name string, v interface{}) error {
+	value, ok := token.Get(name)
+	if !ok || value == nil {
+		return claimError(ErrMissingClaim, name, "")
+	}
+	jsonBytes, err := json.Marshal(value)
+	if err != nil {
+		return &ValidationError{Err: ErrInvalidClaim, Claim: name, Cause: err}
+	}
+	if err := json.Unmarshal(jsonBytes, v); err != nil {
+		return &ValidationError{Err: ErrInvalidClaim, Claim: name, Cause: err}
+	}
+	return nil
+}
+
+// GetString - get a string claim
+func GetString(token *jwt.Token, name string) (string, error) {
+	var value string
+	err := DecodeClaim(token, name, &value)
+	return value, err
+}
+
+// GetBool - get a boolean claim
+func GetBool(token *jwt.Token, name string) (bool, error) {
+	var value bool
+	err := DecodeClaim(token, name, &value)
+	return value, err
+}
+
+// GetNumber - get a numeric claim
+func GetNumber(token *jwt.Token, name string) (float64, error) {
+	var value float64
+	err := DecodeClaim(token, name, &value)
+	return value, err
+}
+
+// GetTime - get a NumericDate claim (seconds since the epoch) such as exp or auth_time
+func GetTime(token *jwt.Token, name string) (time.Time, error) {
+	seconds, err := GetNumber(token, name)
+	if err != nil {
+		return time.Time{}, err
+	}
+	whole, fraction := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(fraction*1e9)), nil
+}
+
+// GetStringSlice - get a claim that is an array of strings - a single string is a one element slice
+func GetStringSlice(token *jwt.Token, name string) ([]string, error) {
+	var values []string
+	if err := DecodeClaim(token, name, &values); err == nil {
+		return values, nil
+	}
+	value, err := GetString(token, name)
+	if err != nil {
+		return nil, err
+	}
+	return []string{value}, nil
+}
+
+// ClaimName - the name of a custom claim in the validator's namespace, e.g. https://example.com/roles -
+// the bare name when WithNamespace is not given, the audiences are never used as a namespace
+func (v *Validator) ClaimName(name string) string {
+	return v.namespace + name
+}
+
+// GetEmail - get email as a custom claim in the validator's namespace from the access_token
+func (v *Validator) GetEmail(token *jwt.Token) (string, error) {
+	return GetString(token, v.ClaimName("email"))
+}
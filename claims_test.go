@@ -0,0 +1,131 @@
+package auth0
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/apibillme/stubby"
+	"github.com/gbrlsnchs/jwt"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestClaims(t *testing.T) {
+
+	Convey("Claim accessors", t, func() {
+		issuer := "https://example.auth0.com/"
+		audience := "https://httpbin.org/"
+
+		stub := stubby.StubFunc(&jwkFetch, testSet(), time.Duration(-1), nil)
+		defer stub.Reset()
+
+		v, err := NewValidator(
+			WithJWKSURL("https://example.auth0.com/jwks.json"),
+			WithAudiences(audience),
+			WithIssuer(issuer),
+			WithNamespace("https://example.com/"),
+		)
+		So(err, ShouldBeNil)
+
+		now := time.Now()
+		type address struct {
+			Country string `json:"country"`
+		}
+		jwtToken := signToken(jwt.NewRS256(testKey, &testKey.PublicKey), testKID, struct {
+			*jwt.JWT
+			Email         string   `json:"https://example.com/email"`
+			Roles         []string `json:"https://example.com/roles"`
+			Group         string   `json:"https://example.com/group"`
+			EmailVerified bool     `json:"email_verified"`
+			Logins        int      `json:"https://example.com/logins"`
+			AuthTime      int64    `json:"auth_time"`
+			Address       address  `json:"address"`
+		}{
+			JWT: &jwt.JWT{
+				Issuer:         issuer,
+				Subject:        "user@email.com",
+				Audience:       audience,
+				ExpirationTime: now.Add(time.Hour).Unix(),
+			},
+			Email:         "user@email.com",
+			Roles:         []string{"admin", "billing"},
+			Group:         "staff",
+			EmailVerified: true,
+			Logins:        7,
+			AuthTime:      now.Add(-time.Minute).Unix(),
+			Address:       address{Country: "NZ"},
+		})
+		token, err := v.ValidateToken(jwtToken)
+		So(err, ShouldBeNil)
+
+		Convey("Success - typed accessors", func() {
+			sub, err := GetString(token, "sub")
+			So(err, ShouldBeNil)
+			So(sub, ShouldEqual, "user@email.com")
+
+			verified, err := GetBool(token, "email_verified")
+			So(err, ShouldBeNil)
+			So(verified, ShouldBeTrue)
+
+			logins, err := GetNumber(token, "https://example.com/logins")
+			So(err, ShouldBeNil)
+			So(logins, ShouldEqual, 7)
+
+			authTime, err := GetTime(token, "auth_time")
+			So(err, ShouldBeNil)
+			So(authTime.Unix(), ShouldEqual, now.Add(-time.Minute).Unix())
+
+			exp, err := GetTime(token, "exp")
+			So(err, ShouldBeNil)
+			So(exp.Unix(), ShouldEqual, now.Add(time.Hour).Unix())
+
+			var addr address
+			So(DecodeClaim(token, "address", &addr), ShouldBeNil)
+			So(addr.Country, ShouldEqual, "NZ")
+		})
+
+		Convey("Success - string slices from arrays & single strings", func() {
+			roles, err := GetStringSlice(token, "https://example.com/roles")
+			So(err, ShouldBeNil)
+			So(roles, ShouldResemble, []string{"admin", "billing"})
+
+			groups, err := GetStringSlice(token, "https://example.com/group")
+			So(err, ShouldBeNil)
+			So(groups, ShouldResemble, []string{"staff"})
+
+			audiences, err := GetStringSlice(token, "aud")
+			So(err, ShouldBeNil)
+			So(audiences, ShouldResemble, []string{audience})
+		})
+
+		Convey("Success - namespaced claims", func() {
+			So(v.ClaimName("roles"), ShouldEqual, "https://example.com/roles")
+			email, err := v.GetEmail(token)
+			So(err, ShouldBeNil)
+			So(email, ShouldEqual, "user@email.com")
+		})
+
+		Convey("Success - claim names are bare without a namespace", func() {
+			v, err := NewValidator(WithJWKSURL("https://example.auth0.com/jwks.json"), WithAudiences(audience))
+			So(err, ShouldBeNil)
+			So(v.ClaimName("email"), ShouldEqual, "email")
+		})
+
+		Convey("Failure - missing claim", func() {
+			_, err := GetString(token, "nickname")
+			So(errors.Is(err, ErrMissingClaim), ShouldBeTrue)
+			var validationErr *ValidationError
+			So(errors.As(err, &validationErr), ShouldBeTrue)
+			So(validationErr.Claim, ShouldEqual, "nickname")
+		})
+
+		Convey("Failure - claim of another type", func() {
+			_, err := GetBool(token, "sub")
+			So(errors.Is(err, ErrInvalidClaim), ShouldBeTrue)
+			_, err = GetNumber(token, "https://example.com/roles")
+			So(errors.Is(err, ErrInvalidClaim), ShouldBeTrue)
+			_, err = GetStringSlice(token, "email_verified")
+			So(errors.Is(err, ErrInvalidClaim), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,127 @@
+package auth0
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+)
+
+// discoveryPath - the OpenID Connect discovery document relative to the issuer
+const discoveryPath = "/.well-known/openid-configuration"
+
+// reference vars here for stubbing
+var discoveryFetch = fetchDiscovery
+
+// discoveryDocument - the parts of the OpenID Connect discovery document the validator uses
+type discoveryDocument struct {
+	Issuer      string   `json:"issuer"`
+	JWKSURI     string   `json:"jwks_uri"`
+	UserInfoURI string   `json:"userinfo_endpoint"`
+	Algorithms  []string `json:"id_token_signing_alg_values_supported"`
+}
+
+// discovery - the discovery document of an issuer, refreshed in the background according to its caching headers
+type discovery struct {
+	mu          sync.Mutex
+	issuer      string
+	client      *http.Client
+	now         func() time.Time
+	jwkURL      string
+	userInfoURL string
+	algorithms  []jwa.SignatureAlgorithm
+	refreshAt   time.Time
+	refreshing  bool
+}
+
+func newDiscovery(issuer string, client *http.Client, now func() time.Time) *discovery {
+	return &discovery{
+		issuer: issuer,
+		client: client,
+		now:    now,
+	}
+}
+
+// keys - the JWKs URL & signing algorithms of the issuer - the last good document is served while refreshing
+func (d *discovery) keys() (string, []jwa.SignatureAlgorithm) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.now().Before(d.refreshAt) && !d.refreshing {
+		d.refreshing = true
+		go d.refresh()
+	}
+	return d.jwkURL, d.algorithms
+}
+
+// userInfo - the userinfo endpoint of the issuer, if it has one
+func (d *discovery) userInfo() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.userInfoURL
+}
+
+// refresh - fetch the document & check it belongs to the issuer
+func (d *discovery) refresh() error {
+	doc, ttl, err := discoveryFetch(d.client, strings.TrimSuffix(d.issuer, "/")+discoveryPath)
+	if err == nil {
+		err = d.check(doc)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.refreshing = false
+	if err != nil {
+		d.refreshAt = d.now().Add(jwksRetryInterval)
+		return err
+	}
+
+	d.jwkURL = doc.JWKSURI
+	d.userInfoURL = doc.UserInfoURI
+	d.algorithms = signingAlgorithms(doc.Algorithms)
+	ttl = clampJWKSTTL(ttl)
+	d.refreshAt = d.now().Add(ttl - ttl/5)
+	return nil
+}
+
+// check - the document must be issued for the issuer it was fetched from (OpenID Connect Discovery section 4.3)
+func (d *discovery) check(doc *discoveryDocument) error {
+	if doc.Issuer != d.issuer {
+		return errors.New("discovery document issuer " + doc.Issuer + " does not match " + d.issuer)
+	}
+	if doc.JWKSURI == "" {
+		return errors.New("discovery document has no jwks_uri")
+	}
+	return nil
+}
+
+// signingAlgorithms - the advertised algorithms that can be verified with a JWK - never none or HMAC
+func signingAlgorithms(advertised []string) []jwa.SignatureAlgorithm {
+	var algorithms []jwa.SignatureAlgorithm
+	for _, alg := range advertised {
+		if alg == "" || alg == jwa.NoSignature.String() || strings.HasPrefix(alg, "HS") {
+			continue
+		}
+		algorithms = append(algorithms, jwa.SignatureAlgorithm(alg))
+	}
+	if len(algorithms) == 0 {
+		return DefaultAlgorithms
+	}
+	return algorithms
+}
+
+// fetchDiscovery - fetch a discovery document and the lifetime advertised for it (negative if none)
+func fetchDiscovery(client *http.Client, discoveryURL string) (*discoveryDocument, time.Duration, error) {
+	buf, ttl, err := fetchDocument(client, discoveryURL)
+	if err != nil {
+		return nil, 0, err
+	}
+	var doc discoveryDocument
+	if err := json.Unmarshal(buf, &doc); err != nil {
+		return nil, 0, err
+	}
+	return &doc, ttl, nil
+}
@@ -0,0 +1,143 @@
+package auth0
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apibillme/cache"
+	"github.com/apibillme/stubby"
+	"github.com/lestrrat-go/jwx/jwa"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDiscovery(t *testing.T) {
+
+	Convey("OpenID Connect discovery", t, func() {
+		stub := stubby.StubFunc(&jwkFetch, testSet(), time.Duration(-1), nil)
+		defer stub.Reset()
+
+		var mu sync.Mutex
+		doc := map[string]interface{}{}
+		status := http.StatusOK
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			if req.URL.Path != discoveryPath {
+				http.NotFound(w, req)
+				return
+			}
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(doc)
+		}))
+		defer server.Close()
+
+		issuer := server.URL + "/"
+		audience := "https://httpbin.org/"
+		doc["issuer"] = issuer
+		doc["jwks_uri"] = server.URL + "/.well-known/jwks.json"
+		doc["id_token_signing_alg_values_supported"] = []string{"HS256", "RS256", "none"}
+
+		now := time.Now()
+		clock := func() time.Time {
+			mu.Lock()
+			defer mu.Unlock()
+			return now
+		}
+
+		Convey("Success - JWKs URL, algorithms & issuer come from the issuer", func() {
+			v, err := NewValidatorFromIssuer(issuer, WithAudiences(audience), WithClock(clock))
+			So(err, ShouldBeNil)
+
+			jwkURL, algorithms := v.keys()
+			So(jwkURL, ShouldEqual, server.URL+"/.well-known/jwks.json")
+			So(algorithms, ShouldResemble, []jwa.SignatureAlgorithm{jwa.RS256})
+
+			_, err = v.ValidateToken(testToken(issuer, audience))
+			So(err, ShouldBeNil)
+			_, err = v.ValidateToken(testToken("https://other.auth0.com/", audience))
+			So(errors.Is(err, ErrInvalidIssuer), ShouldBeTrue)
+		})
+
+		Convey("Success - issuer without a trailing slash", func() {
+			doc["issuer"] = server.URL
+			_, err := NewValidatorFromIssuer(server.URL)
+			So(err, ShouldBeNil)
+		})
+
+		Convey("Success - WithAlgorithms overrides the advertised algorithms", func() {
+			v, err := NewValidatorFromIssuer(issuer, WithAlgorithms(jwa.ES256))
+			So(err, ShouldBeNil)
+			_, algorithms := v.keys()
+			So(algorithms, ShouldResemble, []jwa.SignatureAlgorithm{jwa.ES256})
+		})
+
+		Convey("Failure - token algorithm is not advertised", func() {
+			doc["id_token_signing_alg_values_supported"] = []string{"ES256"}
+			v, err := NewValidatorFromIssuer(issuer, WithAudiences(audience))
+			So(err, ShouldBeNil)
+			_, err = v.ValidateToken(testToken(issuer, audience))
+			So(errors.Is(err, ErrInvalidAlgorithm), ShouldBeTrue)
+		})
+
+		Convey("Failure - document issuer does not match", func() {
+			doc["issuer"] = "https://evil.example.com/"
+			_, err := NewValidatorFromIssuer(issuer)
+			So(err, ShouldBeError)
+		})
+
+		Convey("Failure - cache.New with an invalid capacity is not a cache", func() {
+			_, err := NewValidatorFromIssuer(issuer, WithCache(cache.New(0)))
+			So(err, ShouldBeError)
+		})
+
+		Convey("Failure - document has no jwks_uri", func() {
+			delete(doc, "jwks_uri")
+			_, err := NewValidatorFromIssuer(issuer)
+			So(err, ShouldBeError)
+		})
+
+		Convey("Failure - discovery endpoint is unavailable", func() {
+			status = http.StatusInternalServerError
+			_, err := NewValidatorFromIssuer(issuer)
+			So(err, ShouldBeError)
+		})
+
+		Convey("Refresh - the document is refetched in the background once it nears expiry", func() {
+			v, err := NewValidatorFromIssuer(issuer, WithClock(clock))
+			So(err, ShouldBeNil)
+
+			mu.Lock()
+			doc["jwks_uri"] = server.URL + "/rotated/jwks.json"
+			now = now.Add(time.Minute)
+			mu.Unlock()
+
+			jwkURL, _ := v.keys()
+			So(jwkURL, ShouldEqual, server.URL+"/.well-known/jwks.json")
+			for i := 0; i < 100 && jwkURL != server.URL+"/rotated/jwks.json"; i++ {
+				time.Sleep(10 * time.Millisecond)
+				jwkURL, _ = v.keys()
+			}
+			So(jwkURL, ShouldEqual, server.URL+"/rotated/jwks.json")
+		})
+
+		Convey("Refresh - the last good document is kept when a refetch fails", func() {
+			v, err := NewValidatorFromIssuer(issuer, WithClock(clock))
+			So(err, ShouldBeNil)
+
+			mu.Lock()
+			doc["issuer"] = "https://evil.example.com/"
+			doc["jwks_uri"] = "https://evil.example.com/jwks.json"
+			mu.Unlock()
+			So(v.discovery.refresh(), ShouldBeError)
+
+			jwkURL, _ := v.keys()
+			So(jwkURL, ShouldEqual, server.URL+"/.well-known/jwks.json")
+		})
+	})
+}
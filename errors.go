@@ -0,0 +1,64 @@
+package auth0
+
+import "errors"
+
+// errors returned by validation - compare with errors.Is and use errors.As with *ValidationError for the details
+var (
+	ErrMissingToken     = errors.New("Authorization header is missing")
+	ErrMalformedHeader  = errors.New("Authorization header must have a Bearer token")
+	ErrInvalidScheme    = errors.New("Authorization header scheme is not Bearer")
+	ErrMalformedToken   = errors.New("token is malformed")
+	ErrInvalidAlgorithm = errors.New("algorithm is not allowed")
+	ErrInvalidSignature = errors.New("signature is not valid")
+	ErrExpired          = errors.New("token is expired")
+	ErrNotYetValid      = errors.New("token is not valid yet")
+	ErrTooOld           = errors.New("token is too old")
+	ErrInvalidAudience  = errors.New("audience is not valid")
+	ErrInvalidIssuer    = errors.New("issuer is not valid")
+	ErrJWKSUnavailable  = errors.New("JWKs are unavailable")
+	ErrInactiveToken    = errors.New("token is not active")
+	ErrUnknownKID       = errors.New("unknown kid")
+	ErrMissingClaim     = errors.New("claim is missing")
+	ErrInvalidClaim     = errors.New("claim is not valid")
+	// ErrInsufficientScope - Value holds the required scopes
+	ErrInsufficientScope = errors.New("insufficient scope")
+	// ErrIntrospectionUnavailable - the introspection endpoint failed to answer
+	ErrIntrospectionUnavailable = errors.New("token introspection is unavailable")
+)
+
+// ValidationError - why a token was rejected
+type ValidationError struct {
+	// Err - one of the Err* values
+	Err error
+	// Claim & Value - the offending claim or header and its value, if any
+	Claim string
+	Value string
+	// Cause - the underlying error, if any
+	Cause error
+}
+
+func (e *ValidationError) Error() string {
+	msg := e.Err.Error()
+	if e.Claim != "" {
+		msg += " (" + e.Claim + ": " + e.Value + ")"
+	}
+	if e.Cause != nil {
+		msg += ": " + e.Cause.Error()
+	}
+	return msg
+}
+
+// Unwrap - the Err* value so errors.Is works
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// claimError - err caused by the value of a claim or header
+func claimError(err error, claim string, value string) error {
+	return &ValidationError{Err: err, Claim: claim, Value: value}
+}
+
+// causedError - err caused by an underlying error
+func causedError(err error, cause error) error {
+	return &ValidationError{Err: err, Cause: cause}
+}
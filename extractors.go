@@ -0,0 +1,149 @@
+package auth0
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/spf13/cast"
+	"github.com/valyala/fasthttp"
+)
+
+// TokenExtractor - finds the token in a request - ErrMissingToken when the request has none
+type TokenExtractor interface {
+	Extract(req *http.Request) (string, error)
+	ExtractFast(ctx *fasthttp.RequestCtx) (string, error)
+}
+
+// tokenExtractor - a TokenExtractor from a pair of functions
+type tokenExtractor struct {
+	extract     func(req *http.Request) (string, error)
+	extractFast func(ctx *fasthttp.RequestCtx) (string, error)
+}
+
+func (e tokenExtractor) Extract(req *http.Request) (string, error) {
+	return e.extract(req)
+}
+
+func (e tokenExtractor) ExtractFast(ctx *fasthttp.RequestCtx) (string, error) {
+	return e.extractFast(ctx)
+}
+
+// valueExtractor - a TokenExtractor for sources holding the bare token
+func valueExtractor(extract func(req *http.Request) string, extractFast func(ctx *fasthttp.RequestCtx) []byte) TokenExtractor {
+	return tokenExtractor{
+		extract: func(req *http.Request) (string, error) {
+			return presentToken(extract(req))
+		},
+		extractFast: func(ctx *fasthttp.RequestCtx) (string, error) {
+			return presentToken(cast.ToString(extractFast(ctx)))
+		},
+	}
+}
+
+func presentToken(jwtToken string) (string, error) {
+	if jwtToken == "" {
+		return "", ErrMissingToken
+	}
+	return jwtToken, nil
+}
+
+// defaultExtractors - the Authorization header only when WithExtractors is not given
+var defaultExtractors = []TokenExtractor{FromAuthHeader()}
+
+// FromAuthHeader - the Bearer token of the Authorization header (RFC 6750 section 2.1)
+func FromAuthHeader() TokenExtractor {
+	return tokenExtractor{extract: getJwtTokenNet, extractFast: getJwtTokenFast}
+}
+
+// FromHeader - the bare token in a custom header, e.g. X-Access-Token
+func FromHeader(name string) TokenExtractor {
+	return valueExtractor(
+		func(req *http.Request) string {
+			return req.Header.Get(name)
+		},
+		func(ctx *fasthttp.RequestCtx) []byte {
+			return ctx.Request.Header.Peek(name)
+		},
+	)
+}
+
+// FromCookie - the token in a cookie, e.g. an HttpOnly cookie set for browser clients
+func FromCookie(name string) TokenExtractor {
+	return valueExtractor(
+		func(req *http.Request) string {
+			cookie, err := req.Cookie(name)
+			if err != nil {
+				return ""
+			}
+			return cookie.Value
+		},
+		func(ctx *fasthttp.RequestCtx) []byte {
+			return ctx.Request.Header.Cookie(name)
+		},
+	)
+}
+
+// FromQuery - the token in a query parameter, e.g. access_token (RFC 6750 section 2.3)
+func FromQuery(name string) TokenExtractor {
+	return valueExtractor(
+		func(req *http.Request) string {
+			return req.URL.Query().Get(name)
+		},
+		func(ctx *fasthttp.RequestCtx) []byte {
+			return ctx.QueryArgs().Peek(name)
+		},
+	)
+}
+
+// FromForm - the token in a form-encoded body parameter, e.g. access_token (RFC 6750 section 2.2)
+func FromForm(name string) TokenExtractor {
+	return valueExtractor(
+		func(req *http.Request) string {
+			return req.PostFormValue(name)
+		},
+		func(ctx *fasthttp.RequestCtx) []byte {
+			return ctx.PostArgs().Peek(name)
+		},
+	)
+}
+
+// extract - the token from the first extractor that finds one
+func (v *Validator) extract(req *http.Request) (string, error) {
+	return v.firstToken(func(extractor TokenExtractor) (string, error) {
+		return extractor.Extract(req)
+	})
+}
+
+// extractFast - extract for fasthttp
+func (v *Validator) extractFast(ctx *fasthttp.RequestCtx) (string, error) {
+	return v.firstToken(func(extractor TokenExtractor) (string, error) {
+		return extractor.ExtractFast(ctx)
+	})
+}
+
+// firstToken - try the extractors in order - a malformed value ends the search, another scheme
+// (e.g. Basic credentials meant for something else) does not but is reported if nothing is found
+func (v *Validator) firstToken(extract func(TokenExtractor) (string, error)) (string, error) {
+	notFound := ErrMissingToken
+	for _, extractor := range v.tokenExtractors() {
+		jwtToken, err := extract(extractor)
+		switch {
+		case err == nil:
+			return jwtToken, nil
+		case errors.Is(err, ErrInvalidScheme):
+			if notFound == ErrMissingToken {
+				notFound = err
+			}
+		case !errors.Is(err, ErrMissingToken):
+			return "", err
+		}
+	}
+	return "", notFound
+}
+
+func (v *Validator) tokenExtractors() []TokenExtractor {
+	if len(v.extractors) == 0 {
+		return defaultExtractors
+	}
+	return v.extractors
+}
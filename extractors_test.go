@@ -0,0 +1,140 @@
+package auth0
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apibillme/stubby"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/valyala/fasthttp"
+)
+
+func TestExtractors(t *testing.T) {
+
+	Convey("Token extractors", t, func() {
+		issuer := "https://example.auth0.com/"
+		audience := "https://httpbin.org/"
+
+		stub := stubby.StubFunc(&jwkFetch, testSet(), time.Duration(-1), nil)
+		defer stub.Reset()
+
+		jwtToken := testToken(issuer, audience)
+		v, err := NewValidator(
+			WithJWKSURL("https://example.auth0.com/jwks.json"),
+			WithAudiences(audience),
+			WithIssuer(issuer),
+			WithExtractors(FromAuthHeader(), FromHeader("X-Access-Token"), FromCookie("access_token"), FromQuery("access_token"), FromForm("access_token")),
+		)
+		So(err, ShouldBeNil)
+
+		Convey("net/http", func() {
+			req := httptest.NewRequest("GET", "http://example.com/users", nil)
+
+			Convey("Success - custom header", func() {
+				req.Header.Set("X-Access-Token", jwtToken)
+				_, err := v.Validate(req)
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Success - cookie", func() {
+				req.AddCookie(&http.Cookie{Name: "access_token", Value: jwtToken})
+				_, err := v.Validate(req)
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Success - query parameter", func() {
+				req = httptest.NewRequest("GET", "http://example.com/users?access_token="+jwtToken, nil)
+				_, err := v.Validate(req)
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Success - form-encoded body", func() {
+				form := url.Values{"access_token": {jwtToken}}
+				req = httptest.NewRequest("POST", "http://example.com/users", strings.NewReader(form.Encode()))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+				_, err := v.Validate(req)
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Success - extractors are tried in order", func() {
+				req.Header.Set("Authorization", "Bearer "+jwtToken)
+				req.AddCookie(&http.Cookie{Name: "access_token", Value: "not-a-token"})
+				_, err := v.Validate(req)
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Failure - malformed Authorization header ends the search", func() {
+				req.Header.Set("Authorization", "Bearer "+jwtToken+" "+jwtToken)
+				req.AddCookie(&http.Cookie{Name: "access_token", Value: jwtToken})
+				_, err := v.Validate(req)
+				So(errors.Is(err, ErrMalformedHeader), ShouldBeTrue)
+			})
+
+			Convey("Success - another scheme does not end the search", func() {
+				req.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+				req.AddCookie(&http.Cookie{Name: "access_token", Value: jwtToken})
+				_, err := v.Validate(req)
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Failure - another scheme is reported when no token is found", func() {
+				req.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+				_, err := v.Validate(req)
+				So(errors.Is(err, ErrInvalidScheme), ShouldBeTrue)
+			})
+
+			Convey("Failure - no extractor finds a token", func() {
+				_, err := v.Validate(req)
+				So(errors.Is(err, ErrMissingToken), ShouldBeTrue)
+			})
+
+			Convey("Failure - the default is the Authorization header only", func() {
+				v, err := NewValidator(WithJWKSURL("https://example.auth0.com/jwks.json"))
+				So(err, ShouldBeNil)
+				req.AddCookie(&http.Cookie{Name: "access_token", Value: jwtToken})
+				_, err = v.Validate(req)
+				So(errors.Is(err, ErrMissingToken), ShouldBeTrue)
+			})
+		})
+
+		Convey("fasthttp", func() {
+			ctx := &fasthttp.RequestCtx{}
+
+			Convey("Success - custom header", func() {
+				ctx.Request.Header.Set("X-Access-Token", jwtToken)
+				_, err := v.ValidateFast(ctx)
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Success - cookie", func() {
+				ctx.Request.Header.SetCookie("access_token", jwtToken)
+				_, err := v.ValidateFast(ctx)
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Success - query parameter", func() {
+				ctx.Request.SetRequestURI("/users?access_token=" + jwtToken)
+				_, err := v.ValidateFast(ctx)
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Success - form-encoded body", func() {
+				ctx.Request.Header.SetMethod("POST")
+				ctx.Request.Header.SetContentType("application/x-www-form-urlencoded")
+				ctx.Request.SetBodyString("access_token=" + jwtToken)
+				_, err := v.ValidateFast(ctx)
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Failure - no extractor finds a token", func() {
+				_, err := v.ValidateFast(ctx)
+				So(errors.Is(err, ErrMissingToken), ShouldBeTrue)
+			})
+		})
+	})
+}
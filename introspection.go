@@ -0,0 +1,81 @@
+package auth0
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/lestrrat-go/jwx/jwt"
+)
+
+// introspectionMaxBytes - upper bound on the size of an introspection response
+const introspectionMaxBytes = 1 << 20
+
+// introspection - an RFC 7662 token introspection endpoint & the client credentials it takes
+type introspection struct {
+	endpoint     string
+	clientID     string
+	clientSecret string
+}
+
+// WithIntrospection - validate tokens, e.g. opaque ones, with RFC 7662 introspection at the endpoint instead of
+// JWKs - the client credentials authenticate the validator. Active tokens are cached until their exp.
+func WithIntrospection(endpoint string, clientID string, clientSecret string) Option {
+	return func(v *Validator) {
+		v.introspection = &introspection{
+			endpoint:     endpoint,
+			clientID:     clientID,
+			clientSecret: clientSecret,
+		}
+	}
+}
+
+// introspect - the claims of an active token from the introspection endpoint
+func (v *Validator) introspect(jwtToken string) (*jwt.Token, error) {
+	form := url.Values{"token": {jwtToken}, "token_type_hint": {"access_token"}}
+	req, err := http.NewRequest("POST", v.introspection.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, causedError(ErrIntrospectionUnavailable, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	// client credentials are form-encoded before Basic authentication (RFC 6749 section 2.3.1)
+	req.SetBasicAuth(url.QueryEscape(v.introspection.clientID), url.QueryEscape(v.introspection.clientSecret))
+
+	res, err := v.client.Do(req)
+	if err != nil {
+		return nil, causedError(ErrIntrospectionUnavailable, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, claimError(ErrIntrospectionUnavailable, "status", strconv.Itoa(res.StatusCode))
+	}
+	buf, err := ioutil.ReadAll(io.LimitReader(res.Body, introspectionMaxBytes))
+	if err != nil {
+		return nil, causedError(ErrIntrospectionUnavailable, err)
+	}
+
+	var status struct {
+		Active bool `json:"active"`
+	}
+	if err := json.Unmarshal(buf, &status); err != nil {
+		return nil, causedError(ErrIntrospectionUnavailable, err)
+	}
+	if !status.Active {
+		return nil, ErrInactiveToken
+	}
+
+	// the response members are the claims of the token (RFC 7662 section 2.2)
+	token := jwt.New()
+	if err := token.UnmarshalJSON(buf); err != nil {
+		return nil, causedError(ErrMalformedToken, err)
+	}
+	if err := v.verifyClaims(token); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
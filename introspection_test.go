@@ -0,0 +1,161 @@
+package auth0
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestIntrospection(t *testing.T) {
+
+	Convey("Token introspection", t, func() {
+		audience := "https://api.example.com/"
+		now := time.Now()
+
+		var mu sync.Mutex
+		calls := 0
+		responses := map[string]map[string]interface{}{
+			"opaque-active": {
+				"active":    true,
+				"sub":       "user@email.com",
+				"client_id": "client-id",
+				"scope":     "read:users write:users",
+				"aud":       audience,
+				"exp":       now.Add(time.Hour).Unix(),
+			},
+			"opaque-no-exp": {
+				"active": true,
+				"sub":    "user@email.com",
+				"aud":    []string{"https://other.example.com/", audience},
+			},
+			"opaque-other-aud": {
+				"active": true,
+				"aud":    "https://other.example.com/",
+				"exp":    now.Add(time.Hour).Unix(),
+			},
+			"opaque-expired": {
+				"active": true,
+				"aud":    audience,
+				"exp":    now.Add(-time.Minute).Unix(),
+			},
+		}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls++
+			// credentials are form-encoded (RFC 6749 section 2.3.1)
+			clientID, clientSecret, ok := req.BasicAuth()
+			clientID, _ = url.QueryUnescape(clientID)
+			clientSecret, _ = url.QueryUnescape(clientSecret)
+			if !ok || clientID != "resource-server" || clientSecret != "s3cret/+" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if req.Method != "POST" || req.PostFormValue("token_type_hint") != "access_token" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			response, ok := responses[req.PostFormValue("token")]
+			if !ok {
+				response = map[string]interface{}{"active": false}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(response)
+		}))
+		defer server.Close()
+
+		v, err := NewValidator(
+			WithIntrospection(server.URL, "resource-server", "s3cret/+"),
+			WithAudiences(audience),
+			WithClock(func() time.Time { return now }),
+		)
+		So(err, ShouldBeNil)
+
+		Convey("Success - active token maps onto the claims API", func() {
+			token, err := v.ValidateToken("opaque-active")
+			So(err, ShouldBeNil)
+			So(token.Subject(), ShouldEqual, "user@email.com")
+			So(token.Expiration().Unix(), ShouldEqual, now.Add(time.Hour).Unix())
+			scopes, err := GetScopes(token)
+			So(err, ShouldBeNil)
+			So(scopes, ShouldResemble, []string{"read:users", "write:users"})
+			clientID, err := GetString(token, "client_id")
+			So(err, ShouldBeNil)
+			So(clientID, ShouldEqual, "client-id")
+		})
+
+		Convey("Success - active token is cached until exp", func() {
+			_, err := v.ValidateToken("opaque-active")
+			So(err, ShouldBeNil)
+			_, err = v.ValidateToken("opaque-active")
+			So(err, ShouldBeNil)
+			So(calls, ShouldEqual, 1)
+
+			now = now.Add(time.Hour)
+			_, err = v.ValidateToken("opaque-active")
+			So(errors.Is(err, ErrExpired), ShouldBeTrue)
+			So(calls, ShouldEqual, 2)
+		})
+
+		Convey("Success - active token without exp is accepted but not cached", func() {
+			_, err := v.ValidateToken("opaque-no-exp")
+			So(err, ShouldBeNil)
+			_, err = v.ValidateToken("opaque-no-exp")
+			So(err, ShouldBeNil)
+			So(calls, ShouldEqual, 2)
+		})
+
+		Convey("Success - scope guards work with introspected tokens", func() {
+			called := false
+			handler := v.Handler(RequireScopes("read:users")(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				called = true
+			})))
+			req := httptest.NewRequest("GET", "http://example.com/users", nil)
+			req.Header.Set("Authorization", "Bearer opaque-active")
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			So(called, ShouldBeTrue)
+		})
+
+		Convey("Failure - inactive token is not cached", func() {
+			_, err := v.ValidateToken("opaque-revoked")
+			So(errors.Is(err, ErrInactiveToken), ShouldBeTrue)
+			_, err = v.ValidateToken("opaque-revoked")
+			So(errors.Is(err, ErrInactiveToken), ShouldBeTrue)
+			So(calls, ShouldEqual, 2)
+		})
+
+		Convey("Failure - audience does not match", func() {
+			_, err := v.ValidateToken("opaque-other-aud")
+			So(errors.Is(err, ErrInvalidAudience), ShouldBeTrue)
+		})
+
+		Convey("Failure - expired even though active", func() {
+			_, err := v.ValidateToken("opaque-expired")
+			So(errors.Is(err, ErrExpired), ShouldBeTrue)
+		})
+
+		Convey("Failure - client credentials are rejected", func() {
+			v, err := NewValidator(WithIntrospection(server.URL, "resource-server", "wrong"))
+			So(err, ShouldBeNil)
+			_, err = v.ValidateToken("opaque-active")
+			So(errors.Is(err, ErrIntrospectionUnavailable), ShouldBeTrue)
+
+			status, _ := ErrorResponse(err)
+			So(status, ShouldEqual, http.StatusServiceUnavailable)
+		})
+
+		Convey("Failure - endpoint is unreachable", func() {
+			v, err := NewValidator(WithIntrospection("http://127.0.0.1:1/introspect", "resource-server", "s3cret/+"))
+			So(err, ShouldBeNil)
+			_, err = v.ValidateToken("opaque-active")
+			So(errors.Is(err, ErrIntrospectionUnavailable), ShouldBeTrue)
+		})
+	})
+}
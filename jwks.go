@@ -0,0 +1,282 @@
+package auth0
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+const (
+	// lifetime used when the JWKs response carries no caching headers
+	jwksDefaultTTL = 10 * time.Minute
+	// bounds applied to the lifetime advertised by the JWKs endpoint
+	jwksMinTTL = 15 * time.Second
+	jwksMaxTTL = 24 * time.Hour
+	// how long an expired key set may still be served while the endpoint is unreachable
+	jwksMaxStale = 24 * time.Hour
+	// wait between attempts after a failed fetch
+	jwksRetryInterval = 5 * time.Second
+	// minimum wait between refetches forced by an unknown kid
+	jwksMinRefetchInterval = 30 * time.Second
+	// upper bound on the size of a JWKs (or discovery) document
+	jwksMaxBytes = 1 << 20
+	// how long fetching a JWKs (or discovery) document may take with the default client
+	jwksFetchTimeout = 10 * time.Second
+)
+
+// defaultClient - the HTTP client when none is given - a hung endpoint must not hang token validation
+var defaultClient = &http.Client{Timeout: jwksFetchTimeout}
+
+// jwksCache - JWK sets keyed by URL, refreshed according to the endpoint's caching headers
+type jwksCache struct {
+	mu      sync.Mutex
+	entries map[string]*jwksEntry
+	client  *http.Client
+	now     func() time.Time
+}
+
+type jwksEntry struct {
+	set        *jwk.Set
+	expires    time.Time
+	refreshAt  time.Time
+	refreshing bool
+	lastForced time.Time
+	call       *jwksCall
+}
+
+// jwksCall - an in-flight fetch shared by everyone asking for the same URL
+type jwksCall struct {
+	done chan struct{}
+	set  *jwk.Set
+	err  error
+}
+
+func newJWKSCache(client *http.Client, now func() time.Time) *jwksCache {
+	return &jwksCache{
+		entries: make(map[string]*jwksEntry),
+		client:  client,
+		now:     now,
+	}
+}
+
+// get - return the key set for jwkURL, fetching it only when the cached copy is unusable
+func (c *jwksCache) get(jwkURL string) (*jwk.Set, error) {
+	c.mu.Lock()
+	entry := c.entry(jwkURL)
+	now := c.now()
+
+	// fresh - serve it and refresh in the background once it nears expiry
+	if entry.set != nil && now.Before(entry.expires) {
+		set := entry.set
+		if !now.Before(entry.refreshAt) && !entry.refreshing {
+			entry.refreshing = true
+			go c.fetch(jwkURL, entry)
+		}
+		c.mu.Unlock()
+		return set, nil
+	}
+
+	// stale and the last fetch failed recently, or a fetch is already running - keep serving it
+	// instead of waiting on an endpoint that may be unreachable
+	if c.staleUsable(entry, now) && (now.Before(entry.refreshAt) || entry.call != nil) {
+		set := entry.set
+		c.mu.Unlock()
+		return set, nil
+	}
+	c.mu.Unlock()
+
+	set, err := c.fetch(jwkURL, entry)
+	if err != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.staleUsable(entry, now) {
+			return entry.set, nil
+		}
+		return nil, err
+	}
+	return set, nil
+}
+
+// refresh - refetch the key set after an unknown kid, at most once per jwksMinRefetchInterval
+func (c *jwksCache) refresh(jwkURL string) (*jwk.Set, error) {
+	c.mu.Lock()
+	entry := c.entry(jwkURL)
+	now := c.now()
+	// a fetch is already running or was forced recently - the cached keys will do, never wait on a
+	// fetch that may hang when there are any
+	if entry.set != nil && (entry.call != nil || now.Sub(entry.lastForced) < jwksMinRefetchInterval) {
+		set := entry.set
+		c.mu.Unlock()
+		return set, nil
+	}
+	// join a fetch already in flight when nothing is cached yet
+	if entry.call == nil {
+		entry.lastForced = now
+	}
+	c.mu.Unlock()
+
+	return c.fetch(jwkURL, entry)
+}
+
+// entry - must already hold the lock
+func (c *jwksCache) entry(jwkURL string) *jwksEntry {
+	entry, ok := c.entries[jwkURL]
+	if !ok {
+		entry = &jwksEntry{}
+		c.entries[jwkURL] = entry
+	}
+	return entry
+}
+
+func (c *jwksCache) staleUsable(entry *jwksEntry, now time.Time) bool {
+	return entry.set != nil && now.Before(entry.expires.Add(jwksMaxStale))
+}
+
+// fetch - fetch the key set and store it in the entry, sharing concurrent fetches of the same URL
+func (c *jwksCache) fetch(jwkURL string, entry *jwksEntry) (*jwk.Set, error) {
+	c.mu.Lock()
+	if call := entry.call; call != nil {
+		c.mu.Unlock()
+		<-call.done
+		return call.set, call.err
+	}
+	call := &jwksCall{done: make(chan struct{})}
+	entry.call = call
+	c.mu.Unlock()
+
+	set, ttl, err := jwkFetch(c.client, jwkURL)
+	call.set, call.err = c.store(entry, set, ttl, err)
+	close(call.done)
+	return call.set, call.err
+}
+
+// store - record the result of a fetch in the entry
+func (c *jwksCache) store(entry *jwksEntry, set *jwk.Set, ttl time.Duration, err error) (*jwk.Set, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry.call = nil
+	entry.refreshing = false
+	now := c.now()
+
+	if err != nil {
+		entry.refreshAt = now.Add(jwksRetryInterval)
+		return nil, err
+	}
+
+	ttl = clampJWKSTTL(ttl)
+	entry.set = set
+	entry.expires = now.Add(ttl)
+	// refresh once four fifths of the lifetime has passed
+	entry.refreshAt = now.Add(ttl - ttl/5)
+	return set, nil
+}
+
+func clampJWKSTTL(ttl time.Duration) time.Duration {
+	switch {
+	case ttl < 0:
+		return jwksDefaultTTL
+	case ttl < jwksMinTTL:
+		return jwksMinTTL
+	case ttl > jwksMaxTTL:
+		return jwksMaxTTL
+	}
+	return ttl
+}
+
+// fetchJWKS - fetch a JWK set and the lifetime advertised for it (negative if none)
+func fetchJWKS(client *http.Client, jwkURL string) (*jwk.Set, time.Duration, error) {
+	u, err := url.Parse(jwkURL)
+	if err != nil {
+		return nil, 0, err
+	}
+	// non-HTTP sources (e.g. file://) have no caching headers
+	if u.Scheme != "http" && u.Scheme != "https" {
+		set, err := jwk.Fetch(jwkURL)
+		return set, -1, err
+	}
+
+	buf, ttl, err := fetchDocument(client, jwkURL)
+	if err != nil {
+		return nil, 0, err
+	}
+	set, err := jwk.Parse(buf)
+	if err != nil {
+		return nil, 0, err
+	}
+	return set, ttl, nil
+}
+
+// fetchDocument - GET a document and the lifetime advertised for it (negative if none)
+func fetchDocument(client *http.Client, documentURL string) ([]byte, time.Duration, error) {
+	req, err := http.NewRequest("GET", documentURL, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	return readDocument(client, req)
+}
+
+// readDocument - send the request and read the document in the response
+func readDocument(client *http.Client, req *http.Request) ([]byte, time.Duration, error) {
+	documentURL := req.URL.String()
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, 0, errors.New("failed to fetch " + documentURL + ": status " + strconv.Itoa(res.StatusCode))
+	}
+
+	buf, err := ioutil.ReadAll(io.LimitReader(res.Body, jwksMaxBytes))
+	if err != nil {
+		return nil, 0, err
+	}
+	return buf, cacheLifetime(res.Header, time.Now()), nil
+}
+
+// cacheLifetime - lifetime of a response from its Cache-Control, Age & Expires headers (negative if none)
+func cacheLifetime(header http.Header, now time.Time) time.Duration {
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == "no-cache" || directive == "no-store":
+			return 0
+		case strings.HasPrefix(directive, "max-age="):
+			seconds, err := strconv.ParseInt(strings.TrimPrefix(directive, "max-age="), 10, 64)
+			if err != nil {
+				continue
+			}
+			age, _ := strconv.ParseInt(header.Get("Age"), 10, 64)
+			if seconds -= age; seconds < 0 {
+				return 0
+			}
+			return time.Duration(seconds) * time.Second
+		}
+	}
+
+	expires := header.Get("Expires")
+	if expires == "" {
+		return -1
+	}
+	expiresAt, err := http.ParseTime(expires)
+	if err != nil {
+		// an invalid Expires means already expired (RFC 7234 section 5.3)
+		return 0
+	}
+	if date, err := http.ParseTime(header.Get("Date")); err == nil {
+		now = date
+	}
+	if ttl := expiresAt.Sub(now); ttl > 0 {
+		return ttl
+	}
+	return 0
+}
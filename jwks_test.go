@@ -0,0 +1,233 @@
+package auth0
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apibillme/stubby"
+	"github.com/lestrrat-go/jwx/jwk"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestJWKSCache(t *testing.T) {
+
+	Convey("JWKS Cache", t, func() {
+		jwkURL := "https://example.auth0.com/jwks.json"
+
+		// fake clock
+		now := time.Date(2018, 10, 1, 12, 0, 0, 0, time.UTC)
+		c := newJWKSCache(http.DefaultClient, func() time.Time { return now })
+
+		set := &jwk.Set{}
+		var fetchErr error
+		fetches := 0
+		stub := stubby.Stub(&jwkFetch, func(*http.Client, string) (*jwk.Set, time.Duration, error) {
+			fetches++
+			if fetchErr != nil {
+				return nil, 0, fetchErr
+			}
+			return set, time.Minute, nil
+		})
+		defer stub.Reset()
+
+		Convey("Success - key set is fetched once while fresh", func() {
+			got, err := c.get(jwkURL)
+			So(err, ShouldBeNil)
+			So(got, ShouldEqual, set)
+
+			now = now.Add(30 * time.Second)
+			got, err = c.get(jwkURL)
+			So(err, ShouldBeNil)
+			So(got, ShouldEqual, set)
+			So(fetches, ShouldEqual, 1)
+		})
+
+		Convey("Success - key set is refetched once expired", func() {
+			_, err := c.get(jwkURL)
+			So(err, ShouldBeNil)
+
+			now = now.Add(2 * time.Minute)
+			_, err = c.get(jwkURL)
+			So(err, ShouldBeNil)
+			So(fetches, ShouldEqual, 2)
+		})
+
+		Convey("Success - stale key set is served when the endpoint fails", func() {
+			_, err := c.get(jwkURL)
+			So(err, ShouldBeNil)
+
+			now = now.Add(2 * time.Minute)
+			fetchErr = errors.New("unreachable")
+			got, err := c.get(jwkURL)
+			So(err, ShouldBeNil)
+			So(got, ShouldEqual, set)
+
+			// no refetch until the retry interval has passed
+			_, err = c.get(jwkURL)
+			So(err, ShouldBeNil)
+			So(fetches, ShouldEqual, 2)
+		})
+
+		Convey("Success - stale key set is served while a refetch hangs", func() {
+			_, err := c.get(jwkURL)
+			So(err, ShouldBeNil)
+
+			started := make(chan struct{})
+			release := make(chan struct{})
+			stub.Stub(&jwkFetch, func(*http.Client, string) (*jwk.Set, time.Duration, error) {
+				close(started)
+				<-release
+				return nil, 0, errors.New("unreachable")
+			})
+			defer close(release)
+
+			now = now.Add(2 * time.Minute)
+			go c.get(jwkURL)
+			<-started
+
+			got := make(chan *jwk.Set, 1)
+			go func() {
+				set, _ := c.get(jwkURL)
+				got <- set
+			}()
+			select {
+			case stale := <-got:
+				So(stale, ShouldEqual, set)
+			case <-time.After(time.Second):
+				So("get blocked on the hung refetch", ShouldBeEmpty)
+			}
+		})
+
+		Convey("Success - forced refresh picks up rotated keys once per interval", func() {
+			_, err := c.get(jwkURL)
+			So(err, ShouldBeNil)
+
+			rotated := &jwk.Set{}
+			set = rotated
+			got, err := c.refresh(jwkURL)
+			So(err, ShouldBeNil)
+			So(got, ShouldEqual, rotated)
+			So(fetches, ShouldEqual, 2)
+
+			got, err = c.refresh(jwkURL)
+			So(err, ShouldBeNil)
+			So(got, ShouldEqual, rotated)
+			So(fetches, ShouldEqual, 2)
+
+			now = now.Add(jwksMinRefetchInterval)
+			_, err = c.refresh(jwkURL)
+			So(err, ShouldBeNil)
+			So(fetches, ShouldEqual, 3)
+		})
+
+		Convey("Success - forced refresh returns the cached key set while a refetch hangs", func() {
+			_, err := c.get(jwkURL)
+			So(err, ShouldBeNil)
+
+			started := make(chan struct{})
+			release := make(chan struct{})
+			stub.Stub(&jwkFetch, func(*http.Client, string) (*jwk.Set, time.Duration, error) {
+				close(started)
+				<-release
+				return nil, 0, errors.New("unreachable")
+			})
+			defer close(release)
+
+			now = now.Add(jwksMinRefetchInterval)
+			go c.refresh(jwkURL)
+			<-started
+
+			got := make(chan *jwk.Set, 1)
+			go func() {
+				set, _ := c.refresh(jwkURL)
+				got <- set
+			}()
+			select {
+			case cached := <-got:
+				So(cached, ShouldEqual, set)
+			case <-time.After(time.Second):
+				So("refresh blocked on the hung refetch", ShouldBeEmpty)
+			}
+		})
+
+		Convey("Success - concurrent fetches of the same URL are shared", func() {
+			var mu sync.Mutex
+			calls := 0
+			release := make(chan struct{})
+			stub.Stub(&jwkFetch, func(*http.Client, string) (*jwk.Set, time.Duration, error) {
+				mu.Lock()
+				calls++
+				mu.Unlock()
+				<-release
+				return set, time.Minute, nil
+			})
+
+			var wg sync.WaitGroup
+			errs := make(chan error, 10)
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := c.refresh(jwkURL)
+					errs <- err
+				}()
+			}
+			time.Sleep(10 * time.Millisecond)
+			close(release)
+			wg.Wait()
+			close(errs)
+
+			for err := range errs {
+				So(err, ShouldBeNil)
+			}
+			So(calls, ShouldEqual, 1)
+		})
+
+		Convey("Failure - endpoint fails with nothing cached", func() {
+			fetchErr = errors.New("unreachable")
+			_, err := c.get(jwkURL)
+			So(err, ShouldBeError)
+		})
+
+		Convey("Failure - stale key set is too old", func() {
+			_, err := c.get(jwkURL)
+			So(err, ShouldBeNil)
+
+			now = now.Add(jwksMaxStale + time.Hour)
+			fetchErr = errors.New("unreachable")
+			_, err = c.get(jwkURL)
+			So(err, ShouldBeError)
+		})
+	})
+
+	Convey("cacheLifetime", t, func() {
+		now := time.Date(2018, 10, 1, 12, 0, 0, 0, time.UTC)
+
+		Convey("max-age minus Age", func() {
+			header := http.Header{}
+			header.Set("Cache-Control", "public, max-age=600")
+			header.Set("Age", "100")
+			So(cacheLifetime(header, now), ShouldEqual, 500*time.Second)
+		})
+
+		Convey("no-cache", func() {
+			header := http.Header{}
+			header.Set("Cache-Control", "no-cache")
+			So(cacheLifetime(header, now), ShouldEqual, 0)
+		})
+
+		Convey("Expires relative to Date", func() {
+			header := http.Header{}
+			header.Set("Date", now.Format(http.TimeFormat))
+			header.Set("Expires", now.Add(time.Hour).Format(http.TimeFormat))
+			So(cacheLifetime(header, now), ShouldEqual, time.Hour)
+		})
+
+		Convey("no caching headers", func() {
+			So(cacheLifetime(http.Header{}, now), ShouldBeLessThan, 0)
+		})
+	})
+}
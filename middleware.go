@@ -0,0 +1,207 @@
+package auth0
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/valyala/fasthttp"
+)
+
+// contextKey - keys for the values Handler stores in the request context
+type contextKey int
+
+const (
+	tokenContextKey contextKey = iota
+	errorHandlerContextKey
+)
+
+const (
+	// TokenUserValue - the fasthttp user value HandlerFast stores the token under
+	TokenUserValue = "auth0.token"
+	// errorHandlerUserValue - the fasthttp user value HandlerFast stores its ErrorHandlerFast under
+	errorHandlerUserValue = "auth0.errorHandler"
+)
+
+// ErrorHandler - writes the response for a request rejected by Handler or a guard behind it -
+// err is one of the Err* values, possibly wrapped in a *ValidationError
+type ErrorHandler func(w http.ResponseWriter, req *http.Request, err error)
+
+// ErrorHandlerFast - ErrorHandler for HandlerFast
+type ErrorHandlerFast func(ctx *fasthttp.RequestCtx, err error)
+
+// WithErrorHandler - the ErrorHandler of Handler & the guards behind it - DefaultErrorHandler when not given
+func WithErrorHandler(handler ErrorHandler) Option {
+	return func(v *Validator) {
+		v.errorHandler = handler
+	}
+}
+
+// WithErrorHandlerFast - the ErrorHandlerFast of HandlerFast & the guards behind it - DefaultErrorHandlerFast when not given
+func WithErrorHandlerFast(handler ErrorHandlerFast) Option {
+	return func(v *Validator) {
+		v.errorHandlerFast = handler
+	}
+}
+
+// Handler - net/http middleware that validates the request & stores the token in its context for TokenFromContext
+func (v *Validator) Handler(next http.Handler) http.Handler {
+	return v.handler(next, false)
+}
+
+// OptionalHandler - Handler that lets requests without a token through anonymously (no token in the context) -
+// a token that is present must still be valid
+func (v *Validator) OptionalHandler(next http.Handler) http.Handler {
+	return v.handler(next, true)
+}
+
+func (v *Validator) handler(next http.Handler, optional bool) http.Handler {
+	handleError := v.errorHandler
+	if handleError == nil {
+		handleError = DefaultErrorHandler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx := context.WithValue(req.Context(), errorHandlerContextKey, handleError)
+		token, err := v.Validate(req)
+		switch {
+		case optional && errors.Is(err, ErrMissingToken):
+		case err != nil:
+			handleError(w, req, err)
+			return
+		default:
+			ctx = context.WithValue(ctx, tokenContextKey, token)
+		}
+		next.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
+
+// TokenFromContext - get the token stored by Handler
+func TokenFromContext(ctx context.Context) (*jwt.Token, bool) {
+	token, ok := ctx.Value(tokenContextKey).(*jwt.Token)
+	return token, ok
+}
+
+// HandlerFast - fasthttp middleware that validates the request & stores the token as a user value for TokenFromContextFast
+func (v *Validator) HandlerFast(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return v.handlerFast(next, false)
+}
+
+// OptionalHandlerFast - HandlerFast that lets requests without a token through anonymously (no token user value) -
+// a token that is present must still be valid
+func (v *Validator) OptionalHandlerFast(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return v.handlerFast(next, true)
+}
+
+func (v *Validator) handlerFast(next fasthttp.RequestHandler, optional bool) fasthttp.RequestHandler {
+	handleError := v.errorHandlerFast
+	if handleError == nil {
+		handleError = DefaultErrorHandlerFast
+	}
+	return func(ctx *fasthttp.RequestCtx) {
+		token, err := v.ValidateFast(ctx)
+		switch {
+		case optional && errors.Is(err, ErrMissingToken):
+		case err != nil:
+			handleError(ctx, err)
+			return
+		default:
+			ctx.SetUserValue(TokenUserValue, token)
+		}
+		ctx.SetUserValue(errorHandlerUserValue, handleError)
+		next(ctx)
+	}
+}
+
+// TokenFromContextFast - get the token stored by HandlerFast
+func TokenFromContextFast(ctx *fasthttp.RequestCtx) (*jwt.Token, bool) {
+	token, ok := ctx.UserValue(TokenUserValue).(*jwt.Token)
+	return token, ok
+}
+
+// DefaultErrorHandler - responds with the status code & WWW-Authenticate challenge of ErrorResponse and a plain text body
+func DefaultErrorHandler(w http.ResponseWriter, req *http.Request, err error) {
+	status, authenticate := ErrorResponse(err)
+	if authenticate != "" {
+		w.Header().Set("WWW-Authenticate", authenticate)
+	}
+	http.Error(w, ErrorDescription(err), status)
+}
+
+// DefaultErrorHandlerFast - DefaultErrorHandler for fasthttp
+func DefaultErrorHandlerFast(ctx *fasthttp.RequestCtx, err error) {
+	status, authenticate := ErrorResponse(err)
+	ctx.Error(ErrorDescription(err), status)
+	if authenticate != "" {
+		ctx.Response.Header.Set("WWW-Authenticate", authenticate)
+	}
+}
+
+// rejectRequest - respond with the ErrorHandler of the Handler in front of a guard
+func rejectRequest(w http.ResponseWriter, req *http.Request, err error) {
+	handleError, ok := req.Context().Value(errorHandlerContextKey).(ErrorHandler)
+	if !ok {
+		handleError = DefaultErrorHandler
+	}
+	handleError(w, req, err)
+}
+
+// rejectRequestFast - respond with the ErrorHandlerFast of the HandlerFast in front of a guard
+func rejectRequestFast(ctx *fasthttp.RequestCtx, err error) {
+	handleError, ok := ctx.UserValue(errorHandlerUserValue).(ErrorHandlerFast)
+	if !ok {
+		handleError = DefaultErrorHandlerFast
+	}
+	handleError(ctx, err)
+}
+
+// ErrorResponse - the status code & WWW-Authenticate header for a validation error (RFC 6750 section 3) -
+// the challenge is empty when there should be none
+func ErrorResponse(err error) (int, string) {
+	switch {
+	case errors.Is(err, ErrMissingToken), errors.Is(err, ErrInvalidScheme):
+		// no error code when the request has no (Bearer) authentication information
+		return http.StatusUnauthorized, bearerScheme
+	case errors.Is(err, ErrMalformedHeader):
+		return http.StatusBadRequest, bearerChallenge("invalid_request", ErrorDescription(err))
+	case errors.Is(err, ErrInsufficientScope):
+		challenge := bearerChallenge("insufficient_scope", ErrorDescription(err))
+		// only the scopes required by a guard - URL scope denials never echo the request
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) && validationErr.Claim == "scope" {
+			challenge += `, scope="` + quotedStringSafe(validationErr.Value) + `"`
+		}
+		return http.StatusForbidden, challenge
+	case errors.Is(err, ErrJWKSUnavailable), errors.Is(err, ErrIntrospectionUnavailable):
+		// the token may well be valid - let the client retry
+		return http.StatusServiceUnavailable, ""
+	}
+	return http.StatusUnauthorized, bearerChallenge("invalid_token", ErrorDescription(err))
+}
+
+// bearerChallenge - a WWW-Authenticate Bearer challenge with an error code & description
+func bearerChallenge(code string, description string) string {
+	return `Bearer error="` + code + `", error_description="` + description + `"`
+}
+
+// ErrorDescription - the generic message of a validation error - never echoes token values back
+func ErrorDescription(err error) string {
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		err = validationErr.Err
+	}
+	return quotedStringSafe(err.Error())
+}
+
+// quotedStringSafe - the value made safe for a quoted-string (RFC 7230 section 3.2.6) - quotes & backslashes are
+// replaced and control characters such as CR & LF dropped so nothing can break out of the header
+func quotedStringSafe(value string) string {
+	value = strings.NewReplacer(`"`, `'`, `\`, `/`).Replace(value)
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, value)
+}
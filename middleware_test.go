@@ -0,0 +1,294 @@
+package auth0
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/apibillme/stubby"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/valyala/fasthttp"
+)
+
+func TestMiddleware(t *testing.T) {
+
+	Convey("net/http Middleware", t, func() {
+		issuer := "https://example.auth0.com/"
+		audience := "https://httpbin.org/"
+
+		stub := stubby.StubFunc(&jwkFetch, testSet(), time.Duration(-1), nil)
+		defer stub.Reset()
+
+		v, err := NewValidator(
+			WithJWKSURL("https://example.auth0.com/jwks.json"),
+			WithAudiences(audience),
+			WithIssuer(issuer),
+		)
+		So(err, ShouldBeNil)
+
+		called := false
+		handler := v.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			called = true
+			token, ok := TokenFromContext(req.Context())
+			So(ok, ShouldBeTrue)
+			So(token.Subject(), ShouldEqual, "user@email.com")
+		}))
+
+		req := httptest.NewRequest("GET", "http://example.com/users", nil)
+		res := httptest.NewRecorder()
+
+		Convey("Success - token is in the request context", func() {
+			req.Header.Set("Authorization", "Bearer "+testToken(issuer, audience))
+			handler.ServeHTTP(res, req)
+			So(called, ShouldBeTrue)
+			So(res.Code, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("Failure - missing token has a challenge without error code", func() {
+			handler.ServeHTTP(res, req)
+			So(called, ShouldBeFalse)
+			So(res.Code, ShouldEqual, http.StatusUnauthorized)
+			So(res.Header().Get("WWW-Authenticate"), ShouldEqual, "Bearer")
+		})
+
+		Convey("Failure - invalid token", func() {
+			req.Header.Set("Authorization", "Bearer "+testToken(issuer, "https://other.example.com/"))
+			handler.ServeHTTP(res, req)
+			So(called, ShouldBeFalse)
+			So(res.Code, ShouldEqual, http.StatusUnauthorized)
+			So(res.Header().Get("WWW-Authenticate"), ShouldEqual, `Bearer error="invalid_token", error_description="audience is not valid"`)
+		})
+
+		Convey("Failure - malformed Authorization header", func() {
+			req.Header.Set("Authorization", "Bearer")
+			handler.ServeHTTP(res, req)
+			So(called, ShouldBeFalse)
+			So(res.Code, ShouldEqual, http.StatusBadRequest)
+			So(res.Header().Get("WWW-Authenticate"), ShouldStartWith, `Bearer error="invalid_request"`)
+		})
+
+		Convey("Failure - another scheme has a challenge without error code", func() {
+			req.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+			handler.ServeHTTP(res, req)
+			So(called, ShouldBeFalse)
+			So(res.Code, ShouldEqual, http.StatusUnauthorized)
+			So(res.Header().Get("WWW-Authenticate"), ShouldEqual, "Bearer")
+		})
+
+		Convey("Failure - JWKs unavailable", func() {
+			stub.StubFunc(&jwkFetch, nil, time.Duration(0), errors.New("unreachable"))
+			req.Header.Set("Authorization", "Bearer "+testToken(issuer, audience))
+			handler.ServeHTTP(res, req)
+			So(called, ShouldBeFalse)
+			So(res.Code, ShouldEqual, http.StatusServiceUnavailable)
+		})
+
+		Convey("TokenFromContext - no token", func() {
+			_, ok := TokenFromContext(req.Context())
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("OptionalHandler", func() {
+			var token interface{}
+			optional := v.OptionalHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				called = true
+				token, _ = TokenFromContext(req.Context())
+			}))
+
+			Convey("Success - missing token passes through anonymously", func() {
+				optional.ServeHTTP(res, req)
+				So(called, ShouldBeTrue)
+				So(res.Code, ShouldEqual, http.StatusOK)
+				So(token, ShouldBeNil)
+			})
+
+			Convey("Success - valid token is in the request context", func() {
+				req.Header.Set("Authorization", "Bearer "+testToken(issuer, audience))
+				optional.ServeHTTP(res, req)
+				So(called, ShouldBeTrue)
+				So(token, ShouldNotBeNil)
+			})
+
+			Convey("Failure - invalid token is still rejected", func() {
+				req.Header.Set("Authorization", "Bearer "+testToken(issuer, "https://other.example.com/"))
+				optional.ServeHTTP(res, req)
+				So(called, ShouldBeFalse)
+				So(res.Code, ShouldEqual, http.StatusUnauthorized)
+			})
+
+			Convey("Failure - guards behind it still need a token", func() {
+				v.OptionalHandler(RequireScopes("read:users")(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+					called = true
+				}))).ServeHTTP(res, req)
+				So(called, ShouldBeFalse)
+				So(res.Code, ShouldEqual, http.StatusUnauthorized)
+			})
+		})
+
+		Convey("ErrorHandler - renders Handler & guard errors", func() {
+			problem := func(w http.ResponseWriter, req *http.Request, err error) {
+				status, authenticate := ErrorResponse(err)
+				if authenticate != "" {
+					w.Header().Set("WWW-Authenticate", authenticate)
+				}
+				w.Header().Set("Content-Type", "application/problem+json")
+				w.WriteHeader(status)
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"title":    ErrorDescription(err),
+					"status":   status,
+					"instance": req.URL.Path,
+				})
+			}
+			v, err := NewValidator(
+				WithJWKSURL("https://example.auth0.com/jwks.json"),
+				WithAudiences(audience),
+				WithIssuer(issuer),
+				WithErrorHandler(problem),
+			)
+			So(err, ShouldBeNil)
+			handler := v.Handler(RequireScopes("read:users")(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				called = true
+			})))
+
+			handler.ServeHTTP(res, req)
+			So(res.Code, ShouldEqual, http.StatusUnauthorized)
+			So(res.Header().Get("Content-Type"), ShouldEqual, "application/problem+json")
+			So(res.Header().Get("WWW-Authenticate"), ShouldEqual, "Bearer")
+			So(res.Body.String(), ShouldContainSubstring, `"title":"Authorization header is missing"`)
+
+			req.Header.Set("Authorization", "Bearer "+testToken(issuer, audience))
+			res = httptest.NewRecorder()
+			handler.ServeHTTP(res, req)
+			So(called, ShouldBeFalse)
+			So(res.Code, ShouldEqual, http.StatusForbidden)
+			So(res.Header().Get("Content-Type"), ShouldEqual, "application/problem+json")
+			So(res.Body.String(), ShouldContainSubstring, `"instance":"/users"`)
+		})
+
+		Convey("DefaultErrorHandler - guard without Handler in front", func() {
+			RequireScopes("read:users")(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				called = true
+			})).ServeHTTP(res, req)
+			So(called, ShouldBeFalse)
+			So(res.Code, ShouldEqual, http.StatusUnauthorized)
+			So(res.Body.String(), ShouldEqual, "Authorization header is missing\n")
+		})
+	})
+}
+
+func TestMiddlewareFast(t *testing.T) {
+
+	Convey("fasthttp Middleware", t, func() {
+		issuer := "https://example.auth0.com/"
+		audience := "https://httpbin.org/"
+
+		stub := stubby.StubFunc(&jwkFetch, testSet(), time.Duration(-1), nil)
+		defer stub.Reset()
+
+		v, err := NewValidator(
+			WithJWKSURL("https://example.auth0.com/jwks.json"),
+			WithAudiences(audience),
+			WithIssuer(issuer),
+		)
+		So(err, ShouldBeNil)
+
+		called := false
+		handler := v.HandlerFast(func(ctx *fasthttp.RequestCtx) {
+			called = true
+			token, ok := TokenFromContextFast(ctx)
+			So(ok, ShouldBeTrue)
+			So(token.Subject(), ShouldEqual, "user@email.com")
+		})
+
+		ctx := &fasthttp.RequestCtx{}
+
+		Convey("Success - token is a user value", func() {
+			ctx.Request.Header.Set("Authorization", "Bearer "+testToken(issuer, audience))
+			handler(ctx)
+			So(called, ShouldBeTrue)
+			So(ctx.Response.StatusCode(), ShouldEqual, fasthttp.StatusOK)
+		})
+
+		Convey("Failure - missing token has a challenge without error code", func() {
+			handler(ctx)
+			So(called, ShouldBeFalse)
+			So(ctx.Response.StatusCode(), ShouldEqual, fasthttp.StatusUnauthorized)
+			So(string(ctx.Response.Header.Peek("WWW-Authenticate")), ShouldEqual, "Bearer")
+		})
+
+		Convey("Failure - invalid token", func() {
+			ctx.Request.Header.Set("Authorization", "Bearer "+testToken("https://other.auth0.com/", audience))
+			handler(ctx)
+			So(called, ShouldBeFalse)
+			So(ctx.Response.StatusCode(), ShouldEqual, fasthttp.StatusUnauthorized)
+			So(string(ctx.Response.Header.Peek("WWW-Authenticate")), ShouldEqual, `Bearer error="invalid_token", error_description="issuer is not valid"`)
+		})
+
+		Convey("TokenFromContextFast - no token", func() {
+			_, ok := TokenFromContextFast(ctx)
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("OptionalHandlerFast", func() {
+			hasToken := false
+			optional := v.OptionalHandlerFast(func(ctx *fasthttp.RequestCtx) {
+				called = true
+				_, hasToken = TokenFromContextFast(ctx)
+			})
+
+			Convey("Success - missing token passes through anonymously", func() {
+				optional(ctx)
+				So(called, ShouldBeTrue)
+				So(hasToken, ShouldBeFalse)
+			})
+
+			Convey("Success - valid token is a user value", func() {
+				ctx.Request.Header.Set("Authorization", "Bearer "+testToken(issuer, audience))
+				optional(ctx)
+				So(called, ShouldBeTrue)
+				So(hasToken, ShouldBeTrue)
+			})
+
+			Convey("Failure - invalid token is still rejected", func() {
+				ctx.Request.Header.Set("Authorization", "Bearer not-a-token")
+				optional(ctx)
+				So(called, ShouldBeFalse)
+				So(ctx.Response.StatusCode(), ShouldEqual, fasthttp.StatusUnauthorized)
+			})
+		})
+
+		Convey("ErrorHandlerFast - renders HandlerFast & guard errors", func() {
+			var handled []error
+			v, err := NewValidator(
+				WithJWKSURL("https://example.auth0.com/jwks.json"),
+				WithAudiences(audience),
+				WithIssuer(issuer),
+				WithErrorHandlerFast(func(ctx *fasthttp.RequestCtx, err error) {
+					handled = append(handled, err)
+					status, _ := ErrorResponse(err)
+					ctx.SetStatusCode(status)
+					ctx.SetContentType("application/problem+json")
+				}),
+			)
+			So(err, ShouldBeNil)
+			handler := v.HandlerFast(RequireScopesFast("read:users")(func(ctx *fasthttp.RequestCtx) {
+				called = true
+			}))
+
+			handler(ctx)
+			So(ctx.Response.StatusCode(), ShouldEqual, fasthttp.StatusUnauthorized)
+			So(string(ctx.Response.Header.ContentType()), ShouldEqual, "application/problem+json")
+
+			ctx.Request.Header.Set("Authorization", "Bearer "+testToken(issuer, audience))
+			handler(ctx)
+			So(called, ShouldBeFalse)
+			So(ctx.Response.StatusCode(), ShouldEqual, fasthttp.StatusForbidden)
+			So(len(handled), ShouldEqual, 2)
+			So(errors.Is(handled[0], ErrMissingToken), ShouldBeTrue)
+			So(errors.Is(handled[1], ErrInsufficientScope), ShouldBeTrue)
+		})
+	})
+}
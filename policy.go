@@ -0,0 +1,83 @@
+package auth0
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwt"
+)
+
+// claimValue - a claim that must have an exact value
+type claimValue struct {
+	name  string
+	value interface{}
+}
+
+// WithMaxAge - reject tokens issued (iat) longer ago than maxAge - tokens without iat are rejected too
+func WithMaxAge(maxAge time.Duration) Option {
+	return func(v *Validator) {
+		v.maxAge = maxAge
+	}
+}
+
+// WithRequiredClaims - reject tokens missing any of the claims, e.g. sub, azp or a namespaced claim
+func WithRequiredClaims(names ...string) Option {
+	return func(v *Validator) {
+		v.requiredClaims = append(v.requiredClaims, names...)
+	}
+}
+
+// WithClaimValue - reject tokens whose claim does not have exactly the value (compared as JSON)
+func WithClaimValue(name string, value interface{}) Option {
+	return func(v *Validator) {
+		v.claimValues = append(v.claimValues, claimValue{name: name, value: value})
+	}
+}
+
+// verifyPolicy - the claims policy - exp is always required of JWTs, then the required claims, values & max age
+func (v *Validator) verifyPolicy(token *jwt.Token) error {
+	// introspection responses may omit exp (RFC 7662 section 2.2)
+	if token.Expiration().IsZero() && v.introspection == nil {
+		return claimError(ErrMissingClaim, "exp", "")
+	}
+	for _, name := range v.requiredClaims {
+		if value, ok := token.Get(name); !ok || value == nil {
+			return claimError(ErrMissingClaim, name, "")
+		}
+	}
+	for _, required := range v.claimValues {
+		if err := verifyClaimValue(token, required); err != nil {
+			return err
+		}
+	}
+	if v.maxAge > 0 {
+		iat := token.IssuedAt()
+		if iat.IsZero() {
+			return claimError(ErrMissingClaim, "iat", "")
+		}
+		if v.now().Sub(iat) > v.maxAge+v.leeway {
+			return claimError(ErrTooOld, "iat", iat.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+func verifyClaimValue(token *jwt.Token, required claimValue) error {
+	value, ok := token.Get(required.name)
+	if !ok || value == nil {
+		return claimError(ErrMissingClaim, required.name, "")
+	}
+	actual, err := json.Marshal(value)
+	if err != nil {
+		return &ValidationError{Err: ErrInvalidClaim, Claim: required.name, Cause: err}
+	}
+	expected, err := json.Marshal(required.value)
+	if err != nil {
+		return &ValidationError{Err: ErrInvalidClaim, Claim: required.name, Cause: err}
+	}
+	if !bytes.Equal(actual, expected) {
+		return claimError(ErrInvalidClaim, required.name, string(actual))
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package auth0
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/apibillme/stubby"
+	"github.com/gbrlsnchs/jwt"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPolicy(t *testing.T) {
+
+	Convey("Claims policy", t, func() {
+		issuer := "https://example.auth0.com/"
+		audience := "https://httpbin.org/"
+
+		stub := stubby.StubFunc(&jwkFetch, testSet(), time.Duration(-1), nil)
+		defer stub.Reset()
+
+		now := time.Now()
+		newValidator := func(opts ...Option) *Validator {
+			v, err := NewValidator(append([]Option{
+				WithJWKSURL("https://example.auth0.com/jwks.json"),
+				WithAudiences(audience),
+				WithIssuer(issuer),
+				WithClock(func() time.Time { return now }),
+			}, opts...)...)
+			So(err, ShouldBeNil)
+			return v
+		}
+
+		type orgClaims struct {
+			*jwt.JWT
+			AuthorizedParty string `json:"azp,omitempty"`
+			Org             string `json:"https://example.com/org,omitempty"`
+		}
+		claims := orgClaims{
+			JWT: &jwt.JWT{
+				Issuer:         issuer,
+				Subject:        "user@email.com",
+				Audience:       audience,
+				ExpirationTime: now.Add(time.Hour).Unix(),
+				IssuedAt:       now.Add(-10 * time.Minute).Unix(),
+			},
+			AuthorizedParty: "client-id",
+			Org:             "org_123",
+		}
+		sign := func(claims testClaims) string {
+			return signToken(jwt.NewRS256(testKey, &testKey.PublicKey), testKID, claims)
+		}
+
+		Convey("Success - token satisfies the policy", func() {
+			v := newValidator(
+				WithRequiredClaims("sub", "azp"),
+				WithClaimValue("https://example.com/org", "org_123"),
+				WithMaxAge(time.Hour),
+			)
+			_, err := v.ValidateToken(sign(claims))
+			So(err, ShouldBeNil)
+		})
+
+		Convey("Failure - token without exp", func() {
+			claims.ExpirationTime = 0
+			_, err := newValidator().ValidateToken(sign(claims))
+			So(errors.Is(err, ErrMissingClaim), ShouldBeTrue)
+			var validationErr *ValidationError
+			So(errors.As(err, &validationErr), ShouldBeTrue)
+			So(validationErr.Claim, ShouldEqual, "exp")
+		})
+
+		Convey("Failure - required claim is missing", func() {
+			claims.AuthorizedParty = ""
+			_, err := newValidator(WithRequiredClaims("sub", "azp")).ValidateToken(sign(claims))
+			So(errors.Is(err, ErrMissingClaim), ShouldBeTrue)
+			var validationErr *ValidationError
+			So(errors.As(err, &validationErr), ShouldBeTrue)
+			So(validationErr.Claim, ShouldEqual, "azp")
+		})
+
+		Convey("Failure - claim has another value", func() {
+			claims.Org = "org_456"
+			_, err := newValidator(WithClaimValue("https://example.com/org", "org_123")).ValidateToken(sign(claims))
+			So(errors.Is(err, ErrInvalidClaim), ShouldBeTrue)
+		})
+
+		Convey("Failure - claim with an exact value is missing", func() {
+			claims.Org = ""
+			_, err := newValidator(WithClaimValue("https://example.com/org", "org_123")).ValidateToken(sign(claims))
+			So(errors.Is(err, ErrMissingClaim), ShouldBeTrue)
+		})
+
+		Convey("Failure - token is older than the max age", func() {
+			_, err := newValidator(WithMaxAge(5 * time.Minute)).ValidateToken(sign(claims))
+			So(errors.Is(err, ErrTooOld), ShouldBeTrue)
+		})
+
+		Convey("Failure - max age without iat", func() {
+			claims.IssuedAt = 0
+			_, err := newValidator(WithMaxAge(time.Hour)).ValidateToken(sign(claims))
+			So(errors.Is(err, ErrMissingClaim), ShouldBeTrue)
+		})
+
+		Convey("Failure - cached token ages past the max age", func() {
+			v := newValidator(WithMaxAge(15 * time.Minute))
+			jwtToken := sign(claims)
+			_, err := v.ValidateToken(jwtToken)
+			So(err, ShouldBeNil)
+
+			now = now.Add(10 * time.Minute)
+			_, err = v.ValidateToken(jwtToken)
+			So(errors.Is(err, ErrTooOld), ShouldBeTrue)
+		})
+	})
+}
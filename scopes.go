@@ -0,0 +1,133 @@
+package auth0
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/valyala/fasthttp"
+)
+
+// ScopeSource - the claims the scope guards & Authorize read the scopes from
+type ScopeSource int
+
+const (
+	// ScopeClaim - the space-separated scope claim (the default)
+	ScopeClaim ScopeSource = 1 << iota
+	// PermissionsClaim - the permissions array added by Auth0 RBAC
+	PermissionsClaim
+	// ScopeAndPermissionsClaims - both claims merged
+	ScopeAndPermissionsClaims = ScopeClaim | PermissionsClaim
+)
+
+// Scopes - the scopes of the token read from the source - none when the claims are missing
+func (s ScopeSource) Scopes(token *jwt.Token) []string {
+	var scopes []string
+	if s&ScopeClaim != 0 {
+		scopeClaim, _ := GetScopes(token)
+		scopes = append(scopes, scopeClaim...)
+	}
+	if s&PermissionsClaim != 0 {
+		permissions, _ := GetPermissions(token)
+		scopes = append(scopes, permissions...)
+	}
+	return scopes
+}
+
+// URLScopes - the URL scopes of the token read from the source
+func (s ScopeSource) URLScopes(token *jwt.Token) []URLScope {
+	return parseURLScopes(strings.Join(s.Scopes(token), " "))
+}
+
+// RequireScopes - net/http middleware that lets through only tokens with all the scopes - use behind Handler
+func RequireScopes(all ...string) func(http.Handler) http.Handler {
+	return ScopeClaim.RequireScopes(all...)
+}
+
+// RequireAnyScope - net/http middleware that lets through only tokens with at least one of the scopes - use behind Handler
+func RequireAnyScope(any ...string) func(http.Handler) http.Handler {
+	return ScopeClaim.RequireAnyScope(any...)
+}
+
+// RequireScopesFast - fasthttp middleware that lets through only tokens with all the scopes - use behind HandlerFast
+func RequireScopesFast(all ...string) func(fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return ScopeClaim.RequireScopesFast(all...)
+}
+
+// RequireAnyScopeFast - fasthttp middleware that lets through only tokens with at least one of the scopes - use behind HandlerFast
+func RequireAnyScopeFast(any ...string) func(fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return ScopeClaim.RequireAnyScopeFast(any...)
+}
+
+// RequireScopes - RequireScopes reading the scopes from the source
+func (s ScopeSource) RequireScopes(all ...string) func(http.Handler) http.Handler {
+	return s.requireScopes(all, true)
+}
+
+// RequireAnyScope - RequireAnyScope reading the scopes from the source
+func (s ScopeSource) RequireAnyScope(any ...string) func(http.Handler) http.Handler {
+	return s.requireScopes(any, false)
+}
+
+// RequireScopesFast - RequireScopesFast reading the scopes from the source
+func (s ScopeSource) RequireScopesFast(all ...string) func(fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return s.requireScopesFast(all, true)
+}
+
+// RequireAnyScopeFast - RequireAnyScopeFast reading the scopes from the source
+func (s ScopeSource) RequireAnyScopeFast(any ...string) func(fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return s.requireScopesFast(any, false)
+}
+
+func (s ScopeSource) requireScopes(required []string, all bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			token, ok := TokenFromContext(req.Context())
+			if !ok {
+				rejectRequest(w, req, ErrMissingToken)
+				return
+			}
+			if err := s.checkScopes(token, required, all); err != nil {
+				rejectRequest(w, req, err)
+				return
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+func (s ScopeSource) requireScopesFast(required []string, all bool) func(fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(ctx *fasthttp.RequestCtx) {
+			token, ok := TokenFromContextFast(ctx)
+			if !ok {
+				rejectRequestFast(ctx, ErrMissingToken)
+				return
+			}
+			if err := s.checkScopes(token, required, all); err != nil {
+				rejectRequestFast(ctx, err)
+				return
+			}
+			next(ctx)
+		}
+	}
+}
+
+// checkScopes - ErrInsufficientScope unless the token has all (or any) of the required scopes
+func (s ScopeSource) checkScopes(token *jwt.Token, required []string, all bool) error {
+	granted := make(map[string]bool)
+	for _, scope := range s.Scopes(token) {
+		granted[scope] = true
+	}
+
+	matches := 0
+	for _, scope := range required {
+		if granted[scope] {
+			matches++
+		}
+	}
+	if (all && matches == len(required)) || (!all && matches > 0) {
+		return nil
+	}
+	return claimError(ErrInsufficientScope, "scope", strings.Join(required, " "))
+}
@@ -0,0 +1,158 @@
+package auth0
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/apibillme/stubby"
+	"github.com/gbrlsnchs/jwt"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/valyala/fasthttp"
+)
+
+// scopedToken - a token for the given issuer & audience with the scope claim
+func scopedToken(issuer string, audience string, scope string) string {
+	return rbacToken(issuer, audience, scope, nil)
+}
+
+// rbacToken - a token for the given issuer & audience with the scope & permissions claims
+func rbacToken(issuer string, audience string, scope string, permissions []string) string {
+	now := time.Now()
+	return signToken(jwt.NewRS256(testKey, &testKey.PublicKey), testKID, struct {
+		*jwt.JWT
+		Scope       string   `json:"scope,omitempty"`
+		Permissions []string `json:"permissions,omitempty"`
+	}{
+		JWT: &jwt.JWT{
+			Issuer:         issuer,
+			Subject:        "user@email.com",
+			Audience:       audience,
+			ExpirationTime: now.Add(time.Hour).Unix(),
+			IssuedAt:       now.Unix(),
+		},
+		Scope:       scope,
+		Permissions: permissions,
+	})
+}
+
+func TestScopes(t *testing.T) {
+
+	Convey("Scope guards", t, func() {
+		issuer := "https://example.auth0.com/"
+		audience := "https://httpbin.org/"
+
+		stub := stubby.StubFunc(&jwkFetch, testSet(), time.Duration(-1), nil)
+		defer stub.Reset()
+
+		v, err := NewValidator(
+			WithJWKSURL("https://example.auth0.com/jwks.json"),
+			WithAudiences(audience),
+			WithIssuer(issuer),
+		)
+		So(err, ShouldBeNil)
+
+		called := false
+		next := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			called = true
+		})
+		req := httptest.NewRequest("GET", "http://example.com/users", nil)
+		res := httptest.NewRecorder()
+
+		Convey("RequireScopes - Success - token has all the scopes", func() {
+			req.Header.Set("Authorization", "Bearer "+scopedToken(issuer, audience, "read:users write:users openid"))
+			v.Handler(RequireScopes("read:users", "write:users")(next)).ServeHTTP(res, req)
+			So(called, ShouldBeTrue)
+			So(res.Code, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("RequireScopes - Failure - token misses a scope", func() {
+			req.Header.Set("Authorization", "Bearer "+scopedToken(issuer, audience, "read:users"))
+			v.Handler(RequireScopes("read:users", "write:users")(next)).ServeHTTP(res, req)
+			So(called, ShouldBeFalse)
+			So(res.Code, ShouldEqual, http.StatusForbidden)
+			So(res.Header().Get("WWW-Authenticate"), ShouldEqual, `Bearer error="insufficient_scope", error_description="insufficient scope", scope="read:users write:users"`)
+		})
+
+		Convey("RequireScopes - Failure - token has no scope claim", func() {
+			req.Header.Set("Authorization", "Bearer "+testToken(issuer, audience))
+			v.Handler(RequireScopes("read:users")(next)).ServeHTTP(res, req)
+			So(called, ShouldBeFalse)
+			So(res.Code, ShouldEqual, http.StatusForbidden)
+		})
+
+		Convey("RequireScopes - Failure - not behind Handler", func() {
+			RequireScopes("read:users")(next).ServeHTTP(res, req)
+			So(called, ShouldBeFalse)
+			So(res.Code, ShouldEqual, http.StatusUnauthorized)
+		})
+
+		Convey("RequireAnyScope - Success - token has one of the scopes", func() {
+			req.Header.Set("Authorization", "Bearer "+scopedToken(issuer, audience, "write:users"))
+			v.Handler(RequireAnyScope("read:users", "write:users")(next)).ServeHTTP(res, req)
+			So(called, ShouldBeTrue)
+		})
+
+		Convey("RequireAnyScope - Failure - token has none of the scopes", func() {
+			req.Header.Set("Authorization", "Bearer "+scopedToken(issuer, audience, "openid"))
+			v.Handler(RequireAnyScope("read:users", "write:users")(next)).ServeHTTP(res, req)
+			So(called, ShouldBeFalse)
+			So(res.Code, ShouldEqual, http.StatusForbidden)
+		})
+
+		Convey("GetPermissions", func() {
+			token, err := v.ValidateToken(rbacToken(issuer, audience, "openid", []string{"read:users", "GET:/orders"}))
+			So(err, ShouldBeNil)
+			permissions, err := GetPermissions(token)
+			So(err, ShouldBeNil)
+			So(permissions, ShouldResemble, []string{"read:users", "GET:/orders"})
+
+			token, err = v.ValidateToken(testToken(issuer, audience))
+			So(err, ShouldBeNil)
+			_, err = GetPermissions(token)
+			So(err, ShouldBeError)
+		})
+
+		Convey("ScopeSource - scopes from scope, permissions or both", func() {
+			token, err := v.ValidateToken(rbacToken(issuer, audience, "openid", []string{"read:users", "GET:/orders"}))
+			So(err, ShouldBeNil)
+			So(ScopeClaim.Scopes(token), ShouldResemble, []string{"openid"})
+			So(PermissionsClaim.Scopes(token), ShouldResemble, []string{"read:users", "GET:/orders"})
+			So(ScopeAndPermissionsClaims.Scopes(token), ShouldResemble, []string{"openid", "read:users", "GET:/orders"})
+
+			So(Authorize(token, "GET", "/orders"), ShouldBeError)
+			So(PermissionsClaim.Authorize(token, "GET", "/orders"), ShouldBeNil)
+		})
+
+		Convey("ScopeSource - guards read the permissions claim", func() {
+			req.Header.Set("Authorization", "Bearer "+rbacToken(issuer, audience, "openid", []string{"read:users"}))
+			v.Handler(RequireScopes("read:users")(next)).ServeHTTP(res, req)
+			So(called, ShouldBeFalse)
+			So(res.Code, ShouldEqual, http.StatusForbidden)
+
+			res = httptest.NewRecorder()
+			v.Handler(ScopeAndPermissionsClaims.RequireScopes("openid", "read:users")(next)).ServeHTTP(res, req)
+			So(called, ShouldBeTrue)
+			So(res.Code, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("RequireScopesFast & RequireAnyScopeFast", func() {
+			nextFast := func(ctx *fasthttp.RequestCtx) {
+				called = true
+			}
+			ctx := &fasthttp.RequestCtx{}
+			ctx.Request.Header.Set("Authorization", "Bearer "+scopedToken(issuer, audience, "read:users"))
+
+			v.HandlerFast(RequireScopesFast("read:users", "write:users")(nextFast))(ctx)
+			So(called, ShouldBeFalse)
+			So(ctx.Response.StatusCode(), ShouldEqual, fasthttp.StatusForbidden)
+			So(string(ctx.Response.Header.Peek("WWW-Authenticate")), ShouldEqual, `Bearer error="insufficient_scope", error_description="insufficient scope", scope="read:users write:users"`)
+
+			ctx.Response.Reset()
+			v.HandlerFast(RequireAnyScopeFast("read:users", "write:users")(nextFast))(ctx)
+			So(called, ShouldBeTrue)
+			So(ctx.Response.StatusCode(), ShouldEqual, fasthttp.StatusOK)
+		})
+	})
+}
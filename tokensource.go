@@ -0,0 +1,187 @@
+package auth0
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// tokens are renewed a tenth of their lifetime before they expire, at most this long before
+	tokenExpiryMargin = time.Minute
+	// lifetime assumed when the token endpoint doesn't say (expires_in is only RECOMMENDED by RFC 6749 section 5.1)
+	tokenDefaultLifetime = 5 * time.Minute
+	// upper bound on the size of a token response
+	tokenMaxBytes = 1 << 20
+)
+
+// TokenSource - access tokens of the Auth0 client_credentials grant for one audience, for calling other APIs
+// as a machine client - the token is cached until shortly before it expires & safe for concurrent use
+type TokenSource struct {
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	audience     string
+	client       *http.Client
+	now          func() time.Time
+
+	mu        sync.Mutex
+	token     string
+	expires   time.Time
+	refreshAt time.Time
+	call      *tokenCall
+}
+
+// tokenCall - an in-flight grant shared by everyone asking for a token
+type tokenCall struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+// tokenResponse - the response of the token endpoint (RFC 6749 sections 5.1 & 5.2)
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// NewTokenSource - a token source for the audience (API identifier) from the tenant, e.g. https://tenant.auth0.com/ -
+// the client is http.DefaultClient when nil
+func NewTokenSource(issuer string, clientID string, clientSecret string, audience string, client *http.Client) *TokenSource {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &TokenSource{
+		tokenURL:     strings.TrimSuffix(issuer, "/") + "/oauth/token",
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		audience:     audience,
+		client:       client,
+		now:          time.Now,
+	}
+}
+
+// Token - the cached access token, renewed shortly before it expires - a token that has not expired yet
+// is still returned when renewing it fails
+func (s *TokenSource) Token() (string, error) {
+	s.mu.Lock()
+	now := s.now()
+	if s.token != "" && now.Before(s.refreshAt) {
+		token := s.token
+		s.mu.Unlock()
+		return token, nil
+	}
+	// join a grant already in flight
+	if call := s.call; call != nil {
+		s.mu.Unlock()
+		<-call.done
+		return call.token, call.err
+	}
+	call := &tokenCall{done: make(chan struct{})}
+	s.call = call
+	s.mu.Unlock()
+
+	token, lifetime, err := s.grant()
+
+	s.mu.Lock()
+	now = s.now()
+	switch {
+	case err == nil:
+		s.token = token
+		s.expires = now.Add(lifetime)
+		margin := lifetime / 10
+		if margin > tokenExpiryMargin {
+			margin = tokenExpiryMargin
+		}
+		s.refreshAt = s.expires.Add(-margin)
+	case s.token != "" && now.Before(s.expires):
+		token, err = s.token, nil
+	}
+	s.call = nil
+	s.mu.Unlock()
+
+	call.token, call.err = token, err
+	close(call.done)
+	return token, err
+}
+
+// grant - perform the client_credentials grant (RFC 6749 section 4.4)
+func (s *TokenSource) grant() (string, time.Duration, error) {
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {s.clientID},
+		"client_secret": {s.clientSecret},
+		"audience":      {s.audience},
+	}
+	req, err := http.NewRequest("POST", s.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer res.Body.Close()
+	buf, err := ioutil.ReadAll(io.LimitReader(res.Body, tokenMaxBytes))
+	if err != nil {
+		return "", 0, err
+	}
+
+	var response tokenResponse
+	if err := json.Unmarshal(buf, &response); err != nil {
+		return "", 0, errors.New("client_credentials grant failed: " + res.Status)
+	}
+	if res.StatusCode != http.StatusOK || response.Error != "" {
+		return "", 0, errors.New("client_credentials grant failed: " + res.Status + ": " + response.Error + " " + response.ErrorDescription)
+	}
+	if response.AccessToken == "" || !strings.EqualFold(response.TokenType, bearerScheme) {
+		return "", 0, errors.New("client_credentials grant returned no Bearer token")
+	}
+	if response.ExpiresIn <= 0 {
+		return response.AccessToken, tokenDefaultLifetime, nil
+	}
+	return response.AccessToken, time.Duration(response.ExpiresIn) * time.Second, nil
+}
+
+// Client - an HTTP client that sends the token with every request
+func (s *TokenSource) Client() *http.Client {
+	return &http.Client{Transport: &Transport{Source: s}}
+}
+
+// Transport - an http.RoundTripper that adds the Bearer token of Source to every request
+type Transport struct {
+	Source *TokenSource
+	// Base - the RoundTripper that sends the requests - http.DefaultTransport when nil
+	Base http.RoundTripper
+}
+
+// RoundTrip - send the request with the Authorization header set
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.Source.Token()
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	// a RoundTripper must not modify the request
+	authorized := req.Clone(req.Context())
+	authorized.Header.Set("Authorization", bearerScheme+" "+token)
+	return base.RoundTrip(authorized)
+}
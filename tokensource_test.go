@@ -0,0 +1,155 @@
+package auth0
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTokenSource(t *testing.T) {
+
+	Convey("Client credentials token source", t, func() {
+		var mu sync.Mutex
+		grants := 0
+		failing := false
+		expiresIn := 3600
+		release := make(chan struct{})
+		close(release)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			mu.Lock()
+			grants++
+			grant := grants
+			mu.Unlock()
+			<-release
+
+			w.Header().Set("Content-Type", "application/json")
+			switch {
+			case req.URL.Path == "/api/echo":
+				w.Write([]byte(req.Header.Get("Authorization")))
+			case failing:
+				w.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(w).Encode(map[string]string{"error": "access_denied", "error_description": "Unauthorized"})
+			case req.URL.Path != "/oauth/token" || req.PostFormValue("grant_type") != "client_credentials" ||
+				req.PostFormValue("client_id") != "client-id" || req.PostFormValue("client_secret") != "client-secret" ||
+				req.PostFormValue("audience") != "https://orders.example.com/":
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": "invalid_request"})
+			default:
+				response := map[string]interface{}{
+					"access_token": "token-" + strconv.Itoa(grant),
+					"token_type":   "Bearer",
+				}
+				if expiresIn >= 0 {
+					response["expires_in"] = expiresIn
+				}
+				json.NewEncoder(w).Encode(response)
+			}
+		}))
+		defer server.Close()
+
+		now := time.Now()
+		source := NewTokenSource(server.URL+"/", "client-id", "client-secret", "https://orders.example.com/", nil)
+		source.now = func() time.Time { return now }
+
+		Convey("Success - token is cached until shortly before expiry", func() {
+			token, err := source.Token()
+			So(err, ShouldBeNil)
+			So(token, ShouldEqual, "token-1")
+
+			now = now.Add(58 * time.Minute)
+			token, err = source.Token()
+			So(err, ShouldBeNil)
+			So(token, ShouldEqual, "token-1")
+			So(grants, ShouldEqual, 1)
+
+			now = now.Add(time.Minute)
+			token, err = source.Token()
+			So(err, ShouldBeNil)
+			So(token, ShouldEqual, "token-2")
+		})
+
+		Convey("Success - token without a lifetime is cached for the default lifetime", func() {
+			for _, expiresIn = range []int{-1, 0} {
+				token, err := source.Token()
+				So(err, ShouldBeNil)
+
+				now = now.Add(tokenDefaultLifetime - tokenDefaultLifetime/10 - time.Second)
+				cachedToken, err := source.Token()
+				So(err, ShouldBeNil)
+				So(cachedToken, ShouldEqual, token)
+
+				now = now.Add(time.Second)
+				renewedToken, err := source.Token()
+				So(err, ShouldBeNil)
+				So(renewedToken, ShouldNotEqual, token)
+				now = now.Add(tokenDefaultLifetime)
+			}
+			So(grants, ShouldEqual, 4)
+		})
+
+		Convey("Success - concurrent callers share one grant", func() {
+			release = make(chan struct{})
+			var wg sync.WaitGroup
+			tokens := make([]string, 5)
+			for i := range tokens {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					tokens[i], _ = source.Token()
+				}(i)
+			}
+			time.Sleep(20 * time.Millisecond)
+			close(release)
+			wg.Wait()
+			So(grants, ShouldEqual, 1)
+			So(tokens, ShouldResemble, []string{"token-1", "token-1", "token-1", "token-1", "token-1"})
+		})
+
+		Convey("Success - unexpired token is kept when renewing fails", func() {
+			_, err := source.Token()
+			So(err, ShouldBeNil)
+
+			failing = true
+			now = now.Add(59*time.Minute + 30*time.Second)
+			token, err := source.Token()
+			So(err, ShouldBeNil)
+			So(token, ShouldEqual, "token-1")
+
+			now = now.Add(time.Minute)
+			_, err = source.Token()
+			So(err, ShouldBeError)
+		})
+
+		Convey("Failure - grant is rejected", func() {
+			failing = true
+			_, err := source.Token()
+			So(err, ShouldBeError)
+			So(err.Error(), ShouldContainSubstring, "access_denied")
+		})
+
+		Convey("Transport - adds the Bearer token without modifying the request", func() {
+			req, err := http.NewRequest("GET", server.URL+"/api/echo", nil)
+			So(err, ShouldBeNil)
+			res, err := source.Client().Do(req)
+			So(err, ShouldBeNil)
+			defer res.Body.Close()
+
+			var body [64]byte
+			n, _ := res.Body.Read(body[:])
+			So(string(body[:n]), ShouldEqual, "Bearer token-1")
+			So(req.Header.Get("Authorization"), ShouldBeEmpty)
+		})
+
+		Convey("Transport - fails the request when there is no token", func() {
+			failing = true
+			_, err := source.Client().Get(server.URL + "/api/echo")
+			So(err, ShouldBeError)
+		})
+	})
+}
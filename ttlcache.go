@@ -0,0 +1,57 @@
+package auth0
+
+import (
+	"time"
+
+	"github.com/apibillme/cache"
+)
+
+// TTLCache - a cache.Cache that can also bound the lifetime of a single entry
+type TTLCache interface {
+	cache.Cache
+
+	// SetWithTTL - set a key with a value that expires after ttl (or sooner if the cache says so).
+	// Returns true if an item was evicted.
+	SetWithTTL(key, value interface{}, ttl time.Duration) bool
+}
+
+// ttlEntry - a value stored with its own expiry
+type ttlEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// ttlCache - per-entry TTLs on top of any cache.Cache
+type ttlCache struct {
+	cache.Cache
+	now func() time.Time
+}
+
+// asTTLCache - use the cache as is when it has per-entry TTLs, otherwise wrap it
+func asTTLCache(c cache.Cache, now func() time.Time) TTLCache {
+	if c, ok := c.(TTLCache); ok {
+		return c
+	}
+	return &ttlCache{Cache: c, now: now}
+}
+
+func (c *ttlCache) SetWithTTL(key, value interface{}, ttl time.Duration) bool {
+	return c.Cache.Set(key, ttlEntry{value: value, expires: c.now().Add(ttl)})
+}
+
+// Get - expired entries are deleted and reported as missing
+func (c *ttlCache) Get(key interface{}) (interface{}, bool) {
+	value, ok := c.Cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	entry, ok := value.(ttlEntry)
+	if !ok {
+		return value, true
+	}
+	if !c.now().Before(entry.expires) {
+		c.Cache.Del(key)
+		return nil, false
+	}
+	return entry.value, true
+}
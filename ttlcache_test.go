@@ -0,0 +1,37 @@
+package auth0
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apibillme/cache"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTTLCache(t *testing.T) {
+
+	Convey("TTLCache", t, func() {
+		now := time.Now()
+		c := asTTLCache(cache.New(16, cache.WithTTL(time.Hour)), func() time.Time { return now })
+
+		Convey("Success - entry is served until its own ttl", func() {
+			c.SetWithTTL("key", "value", 30*time.Second)
+			value, ok := c.Get("key")
+			So(ok, ShouldBeTrue)
+			So(value, ShouldEqual, "value")
+
+			now = now.Add(30 * time.Second)
+			_, ok = c.Get("key")
+			So(ok, ShouldBeFalse)
+			So(c.Len(), ShouldEqual, 0)
+		})
+
+		Convey("Success - entries set without a ttl use the cache's ttl", func() {
+			c.Set("key", "value")
+			now = now.Add(30 * time.Minute)
+			value, ok := c.Get("key")
+			So(ok, ShouldBeTrue)
+			So(value, ShouldEqual, "value")
+		})
+	})
+}
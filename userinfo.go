@@ -0,0 +1,148 @@
+package auth0
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apibillme/cache"
+)
+
+// defaultUserInfoTTL - how long a profile is cached when WithUserInfoTTL is not given
+const defaultUserInfoTTL = 5 * time.Minute
+
+// UserInfo - the profile of a user from the userinfo endpoint (OpenID Connect Core section 5.3.2)
+type UserInfo struct {
+	Subject       string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	Picture       string `json:"picture"`
+	// Extra - every member of the response, including the ones above, e.g. nickname or namespaced claims
+	Extra map[string]interface{} `json:"-"`
+}
+
+// WithUserInfoURL - the userinfo endpoint - from the discovery document or the issuer + userinfo when not given
+func WithUserInfoURL(userInfoURL string) Option {
+	return func(v *Validator) {
+		v.userInfoURL = userInfoURL
+	}
+}
+
+// WithUserInfoTTL - how long a profile is cached per subject
+func WithUserInfoTTL(ttl time.Duration) Option {
+	return func(v *Validator) {
+		v.userInfoTTL = ttl
+	}
+}
+
+// userInfoCache - profiles keyed by subject, sharing concurrent fetches for the same subject
+type userInfoCache struct {
+	cache TTLCache
+	mu    sync.Mutex
+	calls map[string]*userInfoCall
+}
+
+// userInfoCall - an in-flight fetch shared by everyone asking for the same subject
+type userInfoCall struct {
+	done chan struct{}
+	info *UserInfo
+	err  error
+}
+
+func newUserInfoCache(now func() time.Time) *userInfoCache {
+	return &userInfoCache{
+		cache: asTTLCache(cache.New(defaultCacheCapacity), now),
+		calls: make(map[string]*userInfoCall),
+	}
+}
+
+// UserInfo - the profile of the access token's subject from the userinfo endpoint, cached per subject -
+// the token is validated first and must carry the openid scope. The profile is shared, do not modify it.
+func (v *Validator) UserInfo(jwtToken string) (*UserInfo, error) {
+	token, err := v.ValidateToken(jwtToken)
+	if err != nil {
+		return nil, err
+	}
+	subject := token.Subject()
+	if subject == "" {
+		return nil, claimError(ErrMissingClaim, "sub", "")
+	}
+	if cached, ok := v.userInfos.cache.Get(subject); ok {
+		return cached.(*UserInfo), nil
+	}
+	return v.userInfos.fetch(subject, v.userInfoTTL, func() (*UserInfo, error) {
+		return v.fetchUserInfo(subject, jwtToken)
+	})
+}
+
+// fetch - fetch the profile of the subject and cache it for ttl
+func (c *userInfoCache) fetch(subject string, ttl time.Duration, fetch func() (*UserInfo, error)) (*UserInfo, error) {
+	c.mu.Lock()
+	if call, ok := c.calls[subject]; ok {
+		c.mu.Unlock()
+		<-call.done
+		return call.info, call.err
+	}
+	call := &userInfoCall{done: make(chan struct{})}
+	c.calls[subject] = call
+	c.mu.Unlock()
+
+	call.info, call.err = fetch()
+	if call.err == nil {
+		c.cache.SetWithTTL(subject, call.info, ttl)
+	}
+
+	c.mu.Lock()
+	delete(c.calls, subject)
+	c.mu.Unlock()
+	close(call.done)
+	return call.info, call.err
+}
+
+// fetchUserInfo - ask the userinfo endpoint for the profile with the access token
+func (v *Validator) fetchUserInfo(subject string, jwtToken string) (*UserInfo, error) {
+	endpoint := v.userInfoEndpoint()
+	if endpoint == "" {
+		return nil, errors.New("there is no userinfo endpoint - use WithUserInfoURL")
+	}
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", bearerScheme+" "+jwtToken)
+	req.Header.Set("Accept", "application/json")
+
+	buf, _, err := readDocument(v.client, req)
+	if err != nil {
+		return nil, err
+	}
+	var info UserInfo
+	if err := json.Unmarshal(buf, &info); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(buf, &info.Extra); err != nil {
+		return nil, err
+	}
+	// the profile must be of the token's subject (OpenID Connect Core section 5.3.2)
+	if info.Subject != subject {
+		return nil, errors.New("userinfo subject " + info.Subject + " does not match the token subject")
+	}
+	return &info, nil
+}
+
+func (v *Validator) userInfoEndpoint() string {
+	switch {
+	case v.userInfoURL != "":
+		return v.userInfoURL
+	case v.discovery != nil && v.discovery.userInfo() != "":
+		return v.discovery.userInfo()
+	case v.issuer != "":
+		// Auth0 serves it at the root of the tenant
+		return strings.TrimSuffix(v.issuer, "/") + "/userinfo"
+	}
+	return ""
+}
@@ -0,0 +1,136 @@
+package auth0
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apibillme/stubby"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUserInfo(t *testing.T) {
+
+	Convey("UserInfo", t, func() {
+		audience := "https://httpbin.org/"
+
+		stub := stubby.StubFunc(&jwkFetch, testSet(), time.Duration(-1), nil)
+		defer stub.Reset()
+
+		var mu sync.Mutex
+		calls := 0
+		release := make(chan struct{})
+		close(release)
+		profile := map[string]interface{}{
+			"sub":                      "user@email.com",
+			"email":                    "user@email.com",
+			"email_verified":           true,
+			"name":                     "User",
+			"picture":                  "https://example.com/user.png",
+			"nickname":                 "user",
+			"https://example.com/plan": "pro",
+		}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			mu.Lock()
+			calls++
+			mu.Unlock()
+			<-release
+			if req.URL.Path != "/userinfo" || req.Header.Get("Authorization") == "" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			json.NewEncoder(w).Encode(profile)
+		}))
+		defer server.Close()
+
+		issuer := server.URL + "/"
+		now := time.Now()
+		v, err := NewValidator(
+			WithJWKSURL("https://example.auth0.com/jwks.json"),
+			WithAudiences(audience),
+			WithIssuer(issuer),
+			WithUserInfoTTL(time.Minute),
+			WithClock(func() time.Time { return now }),
+		)
+		So(err, ShouldBeNil)
+		jwtToken := testToken(issuer, audience)
+
+		Convey("Success - typed profile with the raw extras", func() {
+			info, err := v.UserInfo(jwtToken)
+			So(err, ShouldBeNil)
+			So(info.Subject, ShouldEqual, "user@email.com")
+			So(info.Email, ShouldEqual, "user@email.com")
+			So(info.EmailVerified, ShouldBeTrue)
+			So(info.Name, ShouldEqual, "User")
+			So(info.Picture, ShouldEqual, "https://example.com/user.png")
+			So(info.Extra["nickname"], ShouldEqual, "user")
+			So(info.Extra["https://example.com/plan"], ShouldEqual, "pro")
+		})
+
+		Convey("Success - profile is cached per subject for the TTL", func() {
+			_, err := v.UserInfo(jwtToken)
+			So(err, ShouldBeNil)
+			_, err = v.UserInfo(jwtToken)
+			So(err, ShouldBeNil)
+			So(calls, ShouldEqual, 1)
+
+			now = now.Add(2 * time.Minute)
+			_, err = v.UserInfo(jwtToken)
+			So(err, ShouldBeNil)
+			So(calls, ShouldEqual, 2)
+		})
+
+		Convey("Success - concurrent requests share one fetch", func() {
+			release = make(chan struct{})
+			var wg sync.WaitGroup
+			for i := 0; i < 5; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					v.UserInfo(jwtToken)
+				}()
+			}
+			time.Sleep(20 * time.Millisecond)
+			close(release)
+			wg.Wait()
+			So(calls, ShouldEqual, 1)
+		})
+
+		Convey("Success - WithUserInfoURL", func() {
+			v, err := NewValidator(
+				WithJWKSURL("https://example.auth0.com/jwks.json"),
+				WithUserInfoURL(server.URL+"/userinfo"),
+			)
+			So(err, ShouldBeNil)
+			So(v.userInfoEndpoint(), ShouldEqual, server.URL+"/userinfo")
+		})
+
+		Convey("Failure - profile of another subject", func() {
+			profile["sub"] = "other@email.com"
+			_, err := v.UserInfo(jwtToken)
+			So(err, ShouldBeError)
+		})
+
+		Convey("Failure - invalid token is never sent", func() {
+			_, err := v.UserInfo(testToken(issuer, "https://other.example.com/"))
+			So(errors.Is(err, ErrInvalidAudience), ShouldBeTrue)
+			So(calls, ShouldEqual, 0)
+		})
+
+		Convey("Failure - userinfo endpoint rejects the token", func() {
+			v, err := NewValidator(
+				WithJWKSURL("https://example.auth0.com/jwks.json"),
+				WithAudiences(audience),
+				WithIssuer(issuer),
+				WithUserInfoURL(server.URL+"/other"),
+			)
+			So(err, ShouldBeNil)
+			_, err = v.UserInfo(jwtToken)
+			So(err, ShouldBeError)
+		})
+	})
+}
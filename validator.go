@@ -0,0 +1,246 @@
+package auth0
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/apibillme/cache"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	// token cache defaults when WithCache is not given
+	defaultCacheCapacity = 1024
+	defaultCacheTTL      = 5 * time.Minute
+)
+
+// DefaultAlgorithms - the signature algorithms accepted when WithAlgorithms is not given (Auth0 signs with RS256)
+var DefaultAlgorithms = []jwa.SignatureAlgorithm{jwa.RS256}
+
+// Validator - validates Auth0 access tokens against one JWKs endpoint, audience & issuer
+type Validator struct {
+	jwkURL     string
+	audiences  []string
+	issuer     string
+	namespace  string
+	algorithms []jwa.SignatureAlgorithm
+	cache      TTLCache
+	cacheTTL   time.Duration
+	leeway     time.Duration
+	extractors []TokenExtractor
+	client     *http.Client
+	jwks       *jwksCache
+	discovery  *discovery
+	now        func() time.Time
+	// alternative to JWKs, see introspection.go
+	introspection *introspection
+
+	// claims policy, see policy.go
+	maxAge         time.Duration
+	requiredClaims []string
+	claimValues    []claimValue
+
+	// middleware responses, see middleware.go
+	errorHandler     ErrorHandler
+	errorHandlerFast ErrorHandlerFast
+
+	// profiles, see userinfo.go
+	userInfoURL string
+	userInfoTTL time.Duration
+	userInfos   *userInfoCache
+
+	// the first invalid option, returned by the constructors
+	optionErr error
+}
+
+// Option - configures a Validator
+type Option func(*Validator)
+
+// WithJWKSURL - the JWKs endpoint, e.g. https://tenant.auth0.com/.well-known/jwks.json
+func WithJWKSURL(jwkURL string) Option {
+	return func(v *Validator) {
+		v.jwkURL = jwkURL
+	}
+}
+
+// WithAudiences - the audiences (API identifiers) accepted by the validator
+func WithAudiences(audiences ...string) Option {
+	return func(v *Validator) {
+		v.audiences = append(v.audiences, audiences...)
+	}
+}
+
+// WithIssuer - the issuer the tokens must come from, e.g. https://tenant.auth0.com/
+func WithIssuer(issuer string) Option {
+	return func(v *Validator) {
+		v.issuer = issuer
+	}
+}
+
+// WithNamespace - the namespace of the custom claims read with ClaimName, e.g. https://example.com/
+func WithNamespace(namespace string) Option {
+	return func(v *Validator) {
+		v.namespace = namespace
+	}
+}
+
+// WithAlgorithms - the signature algorithms accepted in the token header & JWK - none is never accepted
+func WithAlgorithms(algorithms ...jwa.SignatureAlgorithm) Option {
+	return func(v *Validator) {
+		v.algorithms = append(v.algorithms, algorithms...)
+	}
+}
+
+// WithCache - the cache for validated tokens - entries never outlive the token's exp
+func WithCache(c cache.Cache) Option {
+	return func(v *Validator) {
+		if c == nil {
+			// cache.New returns nil for an invalid capacity or ttl
+			v.optionErr = errors.New("WithCache was given a nil cache")
+			return
+		}
+		// read the clock through v so WithClock applies whatever the option order
+		v.cache = asTTLCache(c, func() time.Time { return v.now() })
+	}
+}
+
+// WithCacheTTL - the longest a validated token is cached, however far away its exp is
+func WithCacheTTL(ttl time.Duration) Option {
+	return func(v *Validator) {
+		v.cacheTTL = ttl
+	}
+}
+
+// WithClock - the source of the current time
+func WithClock(now func() time.Time) Option {
+	return func(v *Validator) {
+		v.now = now
+	}
+}
+
+// WithLeeway - the clock skew tolerated when checking exp, nbf & iat, e.g. a few seconds
+func WithLeeway(leeway time.Duration) Option {
+	return func(v *Validator) {
+		v.leeway = leeway
+	}
+}
+
+// WithExtractors - where the token is looked for, tried in order - the Authorization header when not given
+func WithExtractors(extractors ...TokenExtractor) Option {
+	return func(v *Validator) {
+		v.extractors = append(v.extractors, extractors...)
+	}
+}
+
+// WithHTTPClient - the HTTP client used to fetch the JWKs & discovery document - a client with a 10 second
+// timeout when not given
+func WithHTTPClient(client *http.Client) Option {
+	return func(v *Validator) {
+		v.client = client
+	}
+}
+
+// NewValidator - create a validator - a JWKs URL (or WithIntrospection) is required
+func NewValidator(opts ...Option) (*Validator, error) {
+	v := newValidator(opts)
+	if v.optionErr != nil {
+		return nil, v.optionErr
+	}
+	if v.jwkURL == "" && v.introspection == nil {
+		return nil, errors.New("a JWKs URL or an introspection endpoint is required")
+	}
+	v.init()
+	return v, nil
+}
+
+// NewValidatorFromIssuer - create a validator for the issuer, e.g. https://tenant.auth0.com/ - the JWKs URL
+// and the signing algorithms (unless WithAlgorithms is given) come from its OpenID Connect discovery document
+func NewValidatorFromIssuer(issuer string, opts ...Option) (*Validator, error) {
+	v := newValidator(opts)
+	if v.optionErr != nil {
+		return nil, v.optionErr
+	}
+	v.issuer = issuer
+	v.discovery = newDiscovery(issuer, v.client, v.now)
+	if err := v.discovery.refresh(); err != nil {
+		return nil, err
+	}
+	v.init()
+	return v, nil
+}
+
+func newValidator(opts []Option) *Validator {
+	v := &Validator{
+		client: defaultClient,
+		now:    time.Now,
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// init - apply the defaults for the options not given
+func (v *Validator) init() {
+	// with discovery the algorithms advertised by the issuer are the default
+	if len(v.algorithms) == 0 && v.discovery == nil {
+		v.algorithms = DefaultAlgorithms
+	}
+	if v.cache == nil && v.introspection != nil {
+		// introspected tokens are cached until their exp
+		v.cache = asTTLCache(cache.New(defaultCacheCapacity), v.now)
+	}
+	if v.cache == nil {
+		v.cache = asTTLCache(cache.New(defaultCacheCapacity, cache.WithTTL(defaultCacheTTL)), v.now)
+		if v.cacheTTL == 0 {
+			v.cacheTTL = defaultCacheTTL
+		}
+	}
+	v.jwks = newJWKSCache(v.client, v.now)
+	if v.userInfoTTL == 0 {
+		v.userInfoTTL = defaultUserInfoTTL
+	}
+	v.userInfos = newUserInfoCache(v.now)
+}
+
+// keys - the JWKs URL & accepted algorithms - from the discovery document unless configured
+func (v *Validator) keys() (string, []jwa.SignatureAlgorithm) {
+	if v.discovery == nil {
+		return v.jwkURL, v.algorithms
+	}
+	jwkURL, algorithms := v.discovery.keys()
+	if len(v.algorithms) > 0 {
+		algorithms = v.algorithms
+	}
+	return jwkURL, algorithms
+}
+
+// ValidateFast - validate the token of a fasthttp request
+func (v *Validator) ValidateFast(req *fasthttp.RequestCtx) (*jwt.Token, error) {
+	// extract token from the request
+	jwtToken, err := v.extractFast(req)
+	if err != nil {
+		return nil, err
+	}
+	// process token
+	return v.processToken(jwtToken)
+}
+
+// Validate - validate the token of a net/http request
+func (v *Validator) Validate(req *http.Request) (*jwt.Token, error) {
+	// extract token from the request
+	jwtToken, err := v.extract(req)
+	if err != nil {
+		return nil, err
+	}
+	// process token
+	return v.processToken(jwtToken)
+}
+
+// ValidateToken - validate a raw access token
+func (v *Validator) ValidateToken(jwtToken string) (*jwt.Token, error) {
+	return v.processToken(jwtToken)
+}
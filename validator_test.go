@@ -0,0 +1,427 @@
+package auth0
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/apibillme/cache"
+	"github.com/apibillme/stubby"
+	"github.com/gbrlsnchs/jwt"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/cast"
+)
+
+// testKID - the kid of the key in testJWKS
+const testKID = "RTAxQzU0MjA0NUM2NzBBQThENzA3RDBDOEVFNDY0NUEyNjc3QkJBQw"
+
+// testKey - signs the test tokens, its public half is the only key in testSet
+var testKey = func() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}()
+
+// testSet - the JWKs holding the public half of testKey
+func testSet() *jwk.Set {
+	key, err := jwk.New(&testKey.PublicKey)
+	if err != nil {
+		panic(err)
+	}
+	key.Set(jwk.KeyIDKey, testKID)
+	key.Set(jwk.AlgorithmKey, "RS256")
+	key.Set(jwk.KeyUsageKey, "sig")
+	return &jwk.Set{Keys: []jwk.Key{key}}
+}
+
+// testToken - a token for the given issuer & audience signed with testKey,
+// issued a minute ago so a test clock read just before minting it is never before its iat
+func testToken(issuer string, audience string) string {
+	now := time.Now()
+	return signToken(jwt.NewRS256(testKey, &testKey.PublicKey), testKID, &jwt.JWT{
+		Issuer:         issuer,
+		Subject:        "user@email.com",
+		Audience:       audience,
+		ExpirationTime: now.Add(time.Hour).Unix(),
+		NotBefore:      now.Add(time.Duration(-10) * time.Minute).Unix(),
+		IssuedAt:       now.Add(-time.Minute).Unix(),
+	})
+}
+
+// testClaims - *jwt.JWT or a struct embedding it to add claims
+type testClaims interface {
+	SetAlgorithm(jwt.Signer)
+	SetKeyID(string)
+}
+
+// signToken - sign the claims with the signer under the kid
+func signToken(signer jwt.Signer, kid string, claims testClaims) string {
+	claims.SetAlgorithm(signer)
+	claims.SetKeyID(kid)
+
+	payload, err := jwt.Marshal(claims)
+	if err != nil {
+		panic(err)
+	}
+	tokenBytes, err := signer.Sign(payload)
+	if err != nil {
+		panic(err)
+	}
+	return cast.ToString(tokenBytes)
+}
+
+func TestValidator(t *testing.T) {
+
+	Convey("Validator", t, func() {
+		jwkEndpoint := "https://example.auth0.com/jwks.json"
+		issuer := "https://example.auth0.com/"
+
+		stub := stubby.StubFunc(&jwkFetch, testSet(), time.Duration(-1), nil)
+		defer stub.Reset()
+
+		Convey("Failure - JWKs URL is required", func() {
+			_, err := NewValidator(WithIssuer(issuer))
+			So(err, ShouldBeError)
+		})
+
+		Convey("Failure - cache.New with an invalid capacity is not a cache", func() {
+			_, err := NewValidator(WithJWKSURL(jwkEndpoint), WithCache(cache.New(0)))
+			So(err, ShouldBeError)
+		})
+
+		Convey("Success - validators are configured independently", func() {
+			users, err := NewValidator(
+				WithJWKSURL(jwkEndpoint),
+				WithAudiences("https://users.example.com/"),
+				WithIssuer(issuer),
+			)
+			So(err, ShouldBeNil)
+			orders, err := NewValidator(
+				WithJWKSURL(jwkEndpoint),
+				WithAudiences("https://orders.example.com/", "https://billing.example.com/"),
+				WithIssuer(issuer),
+			)
+			So(err, ShouldBeNil)
+
+			usersToken := testToken(issuer, "https://users.example.com/")
+			token, err := users.ValidateToken(usersToken)
+			So(err, ShouldBeNil)
+			So(token.Subject(), ShouldEqual, "user@email.com")
+			_, err = orders.ValidateToken(usersToken)
+			So(err, ShouldBeError)
+
+			_, err = orders.ValidateToken(testToken(issuer, "https://billing.example.com/"))
+			So(err, ShouldBeNil)
+		})
+
+		Convey("Success - any value of an array aud matches any accepted audience", func() {
+			v, err := NewValidator(
+				WithJWKSURL(jwkEndpoint),
+				WithAudiences("https://orders.example.com/", "https://httpbin.org/"),
+				WithIssuer(issuer),
+			)
+			So(err, ShouldBeNil)
+
+			claims := struct {
+				*jwt.JWT
+				Audience []string `json:"aud"`
+			}{
+				JWT: &jwt.JWT{
+					Issuer:         issuer,
+					ExpirationTime: time.Now().Add(time.Hour).Unix(),
+				},
+				Audience: []string{"https://httpbin.org/", "https://example.auth0.com/userinfo"},
+			}
+			jwtToken := signToken(jwt.NewRS256(testKey, &testKey.PublicKey), testKID, claims)
+			_, err = v.ValidateToken(jwtToken)
+			So(err, ShouldBeNil)
+
+			claims.Audience = []string{"https://other.example.com/", "https://example.auth0.com/userinfo"}
+			jwtToken = signToken(jwt.NewRS256(testKey, &testKey.PublicKey), testKID, claims)
+			_, err = v.ValidateToken(jwtToken)
+			So(err, ShouldBeError)
+		})
+
+		Convey("Success - validated tokens are cached in the configured cache", func() {
+			c := cache.New(16, cache.WithTTL(time.Minute))
+			v, err := NewValidator(
+				WithJWKSURL(jwkEndpoint),
+				WithAudiences("https://httpbin.org/"),
+				WithIssuer(issuer),
+				WithCache(c),
+				WithHTTPClient(&http.Client{Timeout: time.Second}),
+			)
+			So(err, ShouldBeNil)
+
+			jwtToken := testToken(issuer, "https://httpbin.org/")
+			req, err := http.NewRequest("GET", "http://example.com", nil)
+			So(err, ShouldBeNil)
+			req.Header.Add("Authorization", "Bearer "+jwtToken)
+
+			_, err = v.Validate(req)
+			So(err, ShouldBeNil)
+			_, ok := c.Get(jwtToken)
+			So(ok, ShouldBeTrue)
+		})
+
+		Convey("Failure - a shared cache does not carry a token over to another audience or issuer", func() {
+			c := cache.New(16, cache.WithTTL(time.Minute))
+			users, err := NewValidator(
+				WithJWKSURL(jwkEndpoint),
+				WithAudiences("https://users.example.com/"),
+				WithIssuer(issuer),
+				WithCache(c),
+			)
+			So(err, ShouldBeNil)
+			orders, err := NewValidator(
+				WithJWKSURL(jwkEndpoint),
+				WithAudiences("https://orders.example.com/"),
+				WithIssuer(issuer),
+				WithCache(c),
+			)
+			So(err, ShouldBeNil)
+			otherTenant, err := NewValidator(
+				WithJWKSURL(jwkEndpoint),
+				WithAudiences("https://users.example.com/"),
+				WithIssuer("https://other.auth0.com/"),
+				WithCache(c),
+			)
+			So(err, ShouldBeNil)
+
+			usersToken := testToken(issuer, "https://users.example.com/")
+			_, err = orders.ValidateToken(usersToken)
+			So(errors.Is(err, ErrInvalidAudience), ShouldBeTrue)
+			_, err = users.ValidateToken(usersToken)
+			So(err, ShouldBeNil)
+			_, ok := c.Get(usersToken)
+			So(ok, ShouldBeTrue)
+
+			_, err = orders.ValidateToken(usersToken)
+			So(errors.Is(err, ErrInvalidAudience), ShouldBeTrue)
+			_, err = otherTenant.ValidateToken(usersToken)
+			So(errors.Is(err, ErrInvalidIssuer), ShouldBeTrue)
+			_, err = users.ValidateToken(usersToken)
+			So(err, ShouldBeNil)
+		})
+
+		Convey("Success - cache hit returns the verified claims without parsing", func() {
+			v, err := NewValidator(
+				WithJWKSURL(jwkEndpoint),
+				WithAudiences("https://httpbin.org/"),
+				WithIssuer(issuer),
+			)
+			So(err, ShouldBeNil)
+
+			jwtToken := testToken(issuer, "https://httpbin.org/")
+			token, err := v.ValidateToken(jwtToken)
+			So(err, ShouldBeNil)
+
+			stub.StubFunc(&jwtParseString, nil, errors.New("not parsed again"))
+			cachedToken, err := v.ValidateToken(jwtToken)
+			So(err, ShouldBeNil)
+			So(cachedToken, ShouldEqual, token)
+		})
+
+		Convey("Success - a foreign value in a shared cache is a miss", func() {
+			c := cache.New(16, cache.WithTTL(time.Minute))
+			v, err := NewValidator(
+				WithJWKSURL(jwkEndpoint),
+				WithAudiences("https://httpbin.org/"),
+				WithIssuer(issuer),
+				WithCache(c),
+			)
+			So(err, ShouldBeNil)
+
+			jwtToken := testToken(issuer, "https://httpbin.org/")
+			c.Set(jwtToken, "not a token")
+			token, err := v.ValidateToken(jwtToken)
+			So(err, ShouldBeNil)
+			So(token.Subject(), ShouldEqual, "user@email.com")
+			// the foreign value was replaced by the token
+			cached, ok := c.Get(jwtToken)
+			So(ok, ShouldBeTrue)
+			So(cached, ShouldNotEqual, "not a token")
+		})
+
+		Convey("Failure - cached token is rejected and evicted once expired", func() {
+			now := time.Now()
+			c := cache.New(16, cache.WithTTL(24*time.Hour))
+			v, err := NewValidator(
+				WithJWKSURL(jwkEndpoint),
+				WithAudiences("https://httpbin.org/"),
+				WithIssuer(issuer),
+				WithCache(c),
+				WithClock(func() time.Time { return now }),
+			)
+			So(err, ShouldBeNil)
+
+			jwtToken := testToken(issuer, "https://httpbin.org/")
+			_, err = v.ValidateToken(jwtToken)
+			So(err, ShouldBeNil)
+
+			now = now.Add(2 * time.Hour)
+			_, err = v.ValidateToken(jwtToken)
+			So(err, ShouldBeError)
+			_, ok := c.Get(jwtToken)
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("Success - cache entry is bounded by the token's exp", func() {
+			now := time.Now()
+			v, err := NewValidator(
+				WithJWKSURL(jwkEndpoint),
+				WithAudiences("https://httpbin.org/"),
+				WithIssuer(issuer),
+				WithCacheTTL(24*time.Hour),
+				WithClock(func() time.Time { return now }),
+			)
+			So(err, ShouldBeNil)
+
+			token, err := v.ValidateToken(testToken(issuer, "https://httpbin.org/"))
+			So(err, ShouldBeNil)
+			So(v.entryTTL(token), ShouldBeLessThanOrEqualTo, time.Hour)
+
+			v.cacheTTL = time.Minute
+			So(v.entryTTL(token), ShouldEqual, time.Minute)
+		})
+
+		Convey("Algorithms", func() {
+			v, err := NewValidator(
+				WithJWKSURL(jwkEndpoint),
+				WithAudiences("https://httpbin.org/"),
+				WithIssuer(issuer),
+			)
+			So(err, ShouldBeNil)
+
+			verified := 0
+			stub.Stub(&jwsVerifyWithJWK, func(buf []byte, key jwk.Key) ([]byte, error) {
+				verified++
+				return jws.VerifyWithJWK(buf, key)
+			})
+			jot := &jwt.JWT{
+				Issuer:         issuer,
+				Audience:       "https://httpbin.org/",
+				ExpirationTime: time.Now().Add(time.Hour).Unix(),
+			}
+
+			Convey("Failure - HS256 is rejected before any crypto", func() {
+				_, err := v.ValidateToken(signToken(jwt.NewHS256("secret"), testKID, jot))
+				So(err, ShouldBeError)
+				So(errors.Is(err, ErrInvalidAlgorithm), ShouldBeTrue)
+				So(verified, ShouldEqual, 0)
+			})
+
+			Convey("Failure - none is rejected before any crypto", func() {
+				_, err := v.ValidateToken(signToken(jwt.None(), testKID, jot))
+				So(err, ShouldBeError)
+				So(verified, ShouldEqual, 0)
+			})
+
+			Convey("Failure - allowed token algorithm but the key is for another", func() {
+				v.algorithms = append(v.algorithms, jwa.RS512)
+				_, err := v.ValidateToken(signToken(jwt.NewRS512(testKey, &testKey.PublicKey), testKID, jot))
+				So(err, ShouldBeError)
+				So(errors.Is(err, ErrInvalidAlgorithm), ShouldBeTrue)
+				So(verified, ShouldEqual, 0)
+			})
+
+			Convey("Success - RS256 is verified", func() {
+				_, err := v.ValidateToken(signToken(jwt.NewRS256(testKey, &testKey.PublicKey), testKID, jot))
+				So(err, ShouldBeNil)
+				So(verified, ShouldEqual, 1)
+			})
+		})
+
+		Convey("Failure - clock is after the token expiry", func() {
+			v, err := NewValidator(
+				WithJWKSURL(jwkEndpoint),
+				WithAudiences("https://httpbin.org/"),
+				WithIssuer(issuer),
+				WithClock(func() time.Time { return time.Now().Add(2 * time.Hour) }),
+			)
+			So(err, ShouldBeNil)
+
+			_, err = v.ValidateToken(testToken(issuer, "https://httpbin.org/"))
+			So(err, ShouldBeError)
+		})
+
+		Convey("Leeway", func() {
+			now := time.Now()
+			jwtToken := signToken(jwt.NewRS256(testKey, &testKey.PublicKey), testKID, &jwt.JWT{
+				Issuer:         issuer,
+				Audience:       "https://httpbin.org/",
+				ExpirationTime: now.Add(time.Hour).Unix(),
+				NotBefore:      now.Add(30 * time.Second).Unix(),
+				IssuedAt:       now.Add(30 * time.Second).Unix(),
+			})
+			newValidator := func(leeway time.Duration) *Validator {
+				v, err := NewValidator(
+					WithJWKSURL(jwkEndpoint),
+					WithAudiences("https://httpbin.org/"),
+					WithIssuer(issuer),
+					WithLeeway(leeway),
+					WithCacheTTL(2*time.Hour),
+					WithClock(func() time.Time { return now }),
+				)
+				So(err, ShouldBeNil)
+				return v
+			}
+
+			Convey("Failure - token from a clock ahead of ours without leeway", func() {
+				_, err := newValidator(0).ValidateToken(jwtToken)
+				So(errors.Is(err, ErrNotYetValid), ShouldBeTrue)
+			})
+
+			Convey("Success - nbf & iat within the leeway", func() {
+				_, err := newValidator(time.Minute).ValidateToken(jwtToken)
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Success - cached token past exp but within the leeway", func() {
+				v := newValidator(time.Minute)
+				_, err := v.ValidateToken(jwtToken)
+				So(err, ShouldBeNil)
+
+				now = now.Add(time.Hour + 30*time.Second)
+				_, err = v.ValidateToken(jwtToken)
+				So(err, ShouldBeNil)
+
+				now = now.Add(time.Minute)
+				_, err = v.ValidateToken(jwtToken)
+				So(errors.Is(err, ErrExpired), ShouldBeTrue)
+			})
+
+			Convey("Failure - the time based claim at fault is reported with its value", func() {
+				claimAt := func(claims *jwt.JWT) *ValidationError {
+					claims.Issuer = issuer
+					claims.Audience = "https://httpbin.org/"
+					_, err := newValidator(time.Minute).ValidateToken(signToken(jwt.NewRS256(testKey, &testKey.PublicKey), testKID, claims))
+					var validationErr *ValidationError
+					So(errors.As(err, &validationErr), ShouldBeTrue)
+					return validationErr
+				}
+
+				validationErr := claimAt(&jwt.JWT{ExpirationTime: now.Add(-time.Minute).Unix()})
+				So(validationErr.Err, ShouldEqual, ErrExpired)
+				So(validationErr.Claim, ShouldEqual, "exp")
+				So(validationErr.Value, ShouldEqual, time.Unix(now.Add(-time.Minute).Unix(), 0).Format(time.RFC3339))
+
+				validationErr = claimAt(&jwt.JWT{IssuedAt: now.Add(2 * time.Minute).Unix()})
+				So(validationErr.Err, ShouldEqual, ErrNotYetValid)
+				So(validationErr.Claim, ShouldEqual, "iat")
+
+				validationErr = claimAt(&jwt.JWT{NotBefore: now.Add(2 * time.Minute).Unix()})
+				So(validationErr.Err, ShouldEqual, ErrNotYetValid)
+				So(validationErr.Claim, ShouldEqual, "nbf")
+			})
+		})
+	})
+}